@@ -0,0 +1,227 @@
+package imapclient
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Rights is a set of access rights of ACL (RFC 4314).
+// Besides the standard rights, it keeps the implementation-defined rights "0" to "9"
+// and the other lowercase letters.
+type Rights uint64
+
+const (
+	RightLookup         Rights = 1 << iota // l: visible by LIST
+	RightRead                              // r: SELECT, FETCH, SEARCH, COPY from
+	RightSeen                              // s: keep \Seen
+	RightWrite                             // w: set flags other than \Seen and \Deleted
+	RightInsert                            // i: APPEND, COPY into
+	RightPost                              // p: send mail to the submission address
+	RightCreate                            // k: CREATE child mailboxes, RENAME to
+	RightDelete                            // x: DELETE, RENAME from
+	RightDeleteMessages                    // t: set \Deleted
+	RightExpunge                           // e: EXPUNGE
+	RightAdmin                             // a: SETACL, DELETEACL, GETACL, LISTRIGHTS
+
+	RightAll = RightLookup | RightRead | RightSeen | RightWrite | RightInsert | RightPost |
+		RightCreate | RightDelete | RightDeleteMessages | RightExpunge | RightAdmin
+
+	// rightOther is the first of the bits for "0" to "9" and then "a" to "z"
+	rightOther = RightAdmin << 1
+)
+
+var rightNames = []struct {
+	right Rights
+	name  byte
+}{
+	{RightLookup, 'l'},
+	{RightRead, 'r'},
+	{RightSeen, 's'},
+	{RightWrite, 'w'},
+	{RightInsert, 'i'},
+	{RightPost, 'p'},
+	{RightCreate, 'k'},
+	{RightDelete, 'x'},
+	{RightDeleteMessages, 't'},
+	{RightExpunge, 'e'},
+	{RightAdmin, 'a'},
+}
+
+// ParseRights parses rights such as "lrswipkxtea".
+// The obsolete "c" and "d" (RFC 2086) are parsed as "kx" and "te".
+// Other digits and lowercase letters are kept as they are; any other character is ignored.
+func ParseRights(s string) Rights {
+	var r Rights
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case 'c':
+			r |= RightCreate | RightDelete
+			continue
+		case 'd':
+			r |= RightDeleteMessages | RightExpunge
+			continue
+		}
+		if rn, found := findRight(s[i]); found {
+			r |= rn
+		} else if rn, found := otherRight(s[i]); found {
+			r |= rn
+		}
+	}
+	return r
+}
+
+func findRight(name byte) (Rights, bool) {
+	for _, rn := range rightNames {
+		if name == rn.name {
+			return rn.right, true
+		}
+	}
+	return 0, false
+}
+
+// otherRight returns the bit for a right that is not in rightNames.
+func otherRight(name byte) (Rights, bool) {
+	switch {
+	case '0' <= name && name <= '9':
+		return rightOther << (name - '0'), true
+	case 'a' <= name && name <= 'z':
+		return rightOther << (10 + name - 'a'), true
+	}
+	return 0, false
+}
+
+// Has reports whether r has all of rights.
+func (r Rights) Has(rights Rights) bool {
+	return r&rights == rights
+}
+
+// String returns the rights such as "lrs".
+// The standard rights come first, then the digits and the other letters.
+func (r Rights) String() string {
+	var b strings.Builder
+	for _, rn := range rightNames {
+		if r&rn.right != 0 {
+			b.WriteByte(rn.name)
+		}
+	}
+	for _, name := range "0123456789abcdefghijklmnopqrstuvwxyz" {
+		if _, found := findRight(byte(name)); found {
+			continue
+		}
+		if rn, _ := otherRight(byte(name)); r&rn != 0 {
+			b.WriteByte(byte(name))
+		}
+	}
+	return b.String()
+}
+
+// SetACL replaces the rights of identifier on mailbox (SETACL).
+// identifier such as "-fred" means negative rights.
+func (c *Client) SetACL(mailbox, identifier string, rights Rights) error {
+	return c.setACL(mailbox, identifier, "", rights)
+}
+
+// GrantRights adds rights to the rights of identifier on mailbox (SETACL +).
+func (c *Client) GrantRights(mailbox, identifier string, rights Rights) error {
+	return c.setACL(mailbox, identifier, "+", rights)
+}
+
+// RevokeRights removes rights from the rights of identifier on mailbox (SETACL -).
+func (c *Client) RevokeRights(mailbox, identifier string, rights Rights) error {
+	return c.setACL(mailbox, identifier, "-", rights)
+}
+
+func (c *Client) setACL(mailbox, identifier, mod string, rights Rights) error {
+	mailbox, err := c.quoteMailbox(mailbox)
+	if err != nil {
+		return fmt.Errorf("failed to encode mailbox: %v", err)
+	}
+	_, err = c.Command(fmt.Sprintf("SETACL %v %v %v", mailbox, quoteString(identifier), quoteString(mod+rights.String())))
+	return err
+}
+
+// DeleteACL removes identifier from the ACL of mailbox (DELETEACL).
+func (c *Client) DeleteACL(mailbox, identifier string) error {
+	mailbox, err := c.quoteMailbox(mailbox)
+	if err != nil {
+		return fmt.Errorf("failed to encode mailbox: %v", err)
+	}
+	_, err = c.Command(fmt.Sprintf("DELETEACL %v %v", mailbox, quoteString(identifier)))
+	return err
+}
+
+// GetACL returns the rights of each identifier on mailbox (GETACL).
+func (c *Client) GetACL(mailbox string) (map[string]Rights, error) {
+	fields, err := c.aclCommand("GETACL", "ACL", mailbox, "")
+	if err != nil {
+		return nil, err
+	}
+	if len(fields)%2 != 0 {
+		return nil, fmt.Errorf("unexpected ACL %v", fields)
+	}
+
+	acl := make(map[string]Rights)
+	for i := 0; i < len(fields); i += 2 {
+		acl[fieldString(fields[i])] = ParseRights(fieldString(fields[i+1]))
+	}
+	return acl, nil
+}
+
+// ListRights returns the rights always granted to identifier on mailbox,
+// and the optional rights which can be granted (LISTRIGHTS).
+// The rights in each of optional are granted together.
+func (c *Client) ListRights(mailbox, identifier string) (required Rights, optional []Rights, err error) {
+	fields, err := c.aclCommand("LISTRIGHTS", "LISTRIGHTS", mailbox, identifier)
+	if err != nil {
+		return 0, nil, err
+	}
+	// identifier required optional...
+	if len(fields) < 2 {
+		return 0, nil, fmt.Errorf("unexpected LISTRIGHTS %v", fields)
+	}
+
+	required = ParseRights(fieldString(fields[1]))
+	for _, f := range fields[2:] {
+		optional = append(optional, ParseRights(fieldString(f)))
+	}
+	return required, optional, nil
+}
+
+// MyRights returns the rights of the user on mailbox (MYRIGHTS).
+func (c *Client) MyRights(mailbox string) (Rights, error) {
+	fields, err := c.aclCommand("MYRIGHTS", "MYRIGHTS", mailbox, "")
+	if err != nil {
+		return 0, err
+	}
+	if len(fields) != 1 {
+		return 0, fmt.Errorf("unexpected MYRIGHTS %v", fields)
+	}
+	return ParseRights(fieldString(fields[0])), nil
+}
+
+// aclCommand sends cmd and returns the fields following the mailbox name of the response name.
+func (c *Client) aclCommand(cmd, name, mailbox, identifier string) ([]interface{}, error) {
+	quoted, err := c.quoteMailbox(mailbox)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode mailbox: %v", err)
+	}
+	cmd += " " + quoted
+	if identifier != "" {
+		cmd += " " + quoteString(identifier)
+	}
+
+	res, err := c.Command(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := untaggedLines(res, name)
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("no %v response", name)
+	}
+	fields, err := parseFields(lines[0][len(name):])
+	if err != nil || len(fields) == 0 {
+		return nil, fmt.Errorf("failed to parse %q: %v", lines[0], err)
+	}
+	return fields[1:], nil
+}
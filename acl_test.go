@@ -0,0 +1,32 @@
+package imapclient
+
+import (
+	"testing"
+)
+
+func TestRights(t *testing.T) {
+	cases := []struct {
+		s      string
+		expect string
+	}{
+		{"lrswipkxtea", "lrswipkxtea"},
+		{"arl", "lra"},
+		{"lrcd", "lrkxte"},
+		{"lr0", "lr0"},
+		{"9lz0r", "lr09z"},
+		{"lr-X", "lr"},
+		{"", ""},
+	}
+	for i, cs := range cases {
+		if s := ParseRights(cs.s).String(); s != cs.expect {
+			t.Errorf("%v: ParseRights(%q) %q, expected %q", i, cs.s, s, cs.expect)
+		}
+	}
+
+	if r := ParseRights("lrs"); !r.Has(RightLookup|RightRead) || r.Has(RightRead|RightWrite) {
+		t.Errorf("Has: unexpected result for %v", r)
+	}
+	if s := RightAll.String(); s != "lrswipkxtea" {
+		t.Errorf("RightAll %q, expected %q", s, "lrswipkxtea")
+	}
+}
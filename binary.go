@@ -0,0 +1,93 @@
+package imapclient
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FetchBinary returns the content of section, such as "1" or "1.2", of the messages in seqSet
+// with the Content-Transfer-Encoding decoded by the server (FETCH BINARY.PEEK[section], RFC 3516).
+// section "" means the whole message. It fails if the server does not support BINARY.
+func (c *Client) FetchBinary(seqSet, section string) (map[uint32][]byte, error) {
+	hasBinary, err := c.HasCapability("BINARY")
+	if err != nil {
+		return nil, err
+	}
+	if !hasBinary {
+		return nil, fmt.Errorf("the server does not support BINARY")
+	}
+
+	contents := make(map[uint32][]byte)
+	for _, set := range splitSeqSet(seqSet) {
+		values, err := c.fetchItem(set, "BINARY.PEEK["+section+"]", "BINARY["+section+"]")
+		if err != nil {
+			return nil, err
+		}
+		for seq, v := range values {
+			if v != nil {
+				contents[seq] = []byte(fieldString(v))
+			}
+		}
+	}
+	return contents, nil
+}
+
+// FetchBinarySize returns the size of section decoded, of the messages in seqSet (FETCH BINARY.SIZE[section]).
+func (c *Client) FetchBinarySize(seqSet, section string) (map[uint32]uint32, error) {
+	hasBinary, err := c.HasCapability("BINARY")
+	if err != nil {
+		return nil, err
+	}
+	if !hasBinary {
+		return nil, fmt.Errorf("the server does not support BINARY")
+	}
+
+	sizes := make(map[uint32]uint32)
+	for _, set := range splitSeqSet(seqSet) {
+		values, err := c.fetchItem(set, "BINARY.SIZE["+section+"]", "BINARY.SIZE["+section+"]")
+		if err != nil {
+			return nil, err
+		}
+		for seq, v := range values {
+			size, err := fieldUint32(v)
+			if err != nil {
+				return nil, err
+			}
+			sizes[seq] = size
+		}
+	}
+	return sizes, nil
+}
+
+// fetchItem fetches item of the messages in seqSet, and returns the values of name in the response.
+func (c *Client) fetchItem(seqSet, item, name string) (map[uint32]interface{}, error) {
+	res, err := c.Command(fmt.Sprintf("FETCH %v (%v)", seqSet, item))
+	if err != nil {
+		return nil, err
+	}
+	return parseFetchItem(res, name)
+}
+
+// parseFetchItem returns the values of name, such as "BINARY[1]", of FETCH responses in res.
+func parseFetchItem(res, name string) (map[uint32]interface{}, error) {
+	values := make(map[uint32]interface{})
+	for _, line := range splitResponse(res) {
+		seq, items, found := fetchItems(line)
+		if !found {
+			continue
+		}
+
+		fields, err := parseFields(items)
+		if err != nil || len(fields) != 1 {
+			return nil, fmt.Errorf("failed to parse FETCH %q: %v", line, err)
+		}
+
+		attrs := fieldList(fields[0])
+		for i := 0; i+1 < len(attrs); i += 2 {
+			if strings.EqualFold(fieldString(attrs[i]), name) {
+				values[seq] = attrs[i+1]
+			}
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,122 @@
+package imapclient
+
+import (
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+func TestParseFetchItem(t *testing.T) {
+	res := "* 1 FETCH (UID 10 BINARY[1] ~{5}\r\nab\x00cd BINARY.SIZE[1] 5)\r\n" +
+		"* 2 FETCH (BINARY[1] NIL)\r\n" +
+		"A1 OK FETCH completed\r\n"
+
+	values, err := parseFetchItem(res, "BINARY[1]")
+	if err != nil {
+		t.Fatalf("parseFetchItem: %v", err)
+	}
+	if len(values) != 2 || fieldString(values[1]) != "ab\x00cd" || values[2] != nil {
+		t.Errorf("unexpected values %#v", values)
+	}
+
+	values, err = parseFetchItem(res, "binary.size[1]")
+	if err != nil || len(values) != 1 || fieldString(values[1]) != "5" {
+		t.Errorf("unexpected values %#v, %v", values, err)
+	}
+}
+
+func TestFetchBinary(t *testing.T) {
+	c, done := newFakeServer(t, func(s *fakeServer) {
+		s.expect("A1 FETCH 1:2 (BINARY.PEEK[1])")
+		s.send("* 1 FETCH (BINARY[1] ~{5}\r\nab\x00cd)", "* 2 FETCH (BINARY[1] NIL)", "A1 OK FETCH completed")
+		s.expect("A2 FETCH 1 (BINARY.SIZE[])")
+		s.send("* 1 FETCH (BINARY.SIZE[] 1234)", "A2 OK FETCH completed")
+	})
+	c.caps = []string{"IMAP4rev1", "BINARY"}
+	c.mailbox = &MailboxStatus{Name: "INBOX"}
+
+	contents, err := c.FetchBinary("1:2", "1")
+	if err != nil {
+		t.Fatalf("FetchBinary: %v", err)
+	}
+	if len(contents) != 1 || string(contents[1]) != "ab\x00cd" {
+		t.Errorf("FetchBinary %v, expected only 1: %q", contents, "ab\x00cd")
+	}
+
+	sizes, err := c.FetchBinarySize("1", "")
+	if err != nil {
+		t.Fatalf("FetchBinarySize: %v", err)
+	}
+	if len(sizes) != 1 || sizes[1] != 1234 {
+		t.Errorf("FetchBinarySize %v, expected map[1:1234]", sizes)
+	}
+	<-done
+}
+
+func TestFetchBinaryNotSupported(t *testing.T) {
+	c, done := newFakeServer(t, func(s *fakeServer) {})
+	c.caps = []string{"IMAP4rev1"}
+	c.mailbox = &MailboxStatus{Name: "INBOX"}
+
+	if _, err := c.FetchBinary("1", "1"); err == nil {
+		t.Errorf("FetchBinary succeeded, expected an error")
+	}
+	if _, err := c.FetchBinarySize("1", "1"); err == nil {
+		t.Errorf("FetchBinarySize succeeded, expected an error")
+	}
+	<-done
+}
+
+func TestAppendBinary(t *testing.T) {
+	cases := []struct {
+		caps   []string
+		header mail.Header
+		body   string
+		expect bool // sent as literal8, or refused
+	}{
+		{[]string{"IMAP4rev1", "BINARY"}, mail.Header{"Content-Transfer-Encoding": {"binary"}}, "ab\x00cd", true},
+		{[]string{"IMAP4rev1", "BINARY"}, mail.Header{"Content-Transfer-Encoding": {"Binary "}}, "abcd", true},
+		{[]string{"IMAP4rev1", "BINARY"}, mail.Header{"Content-Transfer-Encoding": {"8bit"}}, "abcd", false},
+		{[]string{"IMAP4rev1"}, mail.Header{"Content-Transfer-Encoding": {"binary"}}, "ab\x00cd", true},
+	}
+
+	for i, cs := range cases {
+		hasBinary := len(cs.caps) > 1
+		var contents string
+		c, done := newFakeServer(t, func(s *fakeServer) {
+			if cs.expect && !hasBinary {
+				return
+			}
+			line, _ := s.r.ReadString('\n')
+			line = strings.TrimSuffix(line, "\r\n")
+			prefix := `A1 APPEND "INBOX" {`
+			if cs.expect {
+				prefix = `A1 APPEND "INBOX" ~{`
+			}
+			n, found := literalLength(line)
+			if !strings.HasPrefix(line, prefix) || !found {
+				t.Errorf("%v: server: got %q, expected %q", i, line, prefix+"n}")
+				return
+			}
+			s.send("+ Ready")
+			contents = s.read(n)
+			s.expect("")
+			s.send("A1 OK APPEND completed")
+		})
+		c.caps = cs.caps
+
+		err := c.Append("INBOX", nil, mail.Message{Header: cs.header, Body: strings.NewReader(cs.body)})
+		<-done
+
+		switch {
+		case cs.expect && !hasBinary:
+			if err == nil {
+				t.Errorf("%v: Append succeeded, expected an error", i)
+			}
+		case err != nil:
+			t.Errorf("%v: Append: %v", i, err)
+		case !strings.HasSuffix(contents, "\r\n\r\n"+cs.body+"\r\n\r\n"):
+			t.Errorf("%v: unexpected contents %q", i, contents)
+		}
+	}
+}
@@ -1,578 +1,1330 @@
-package imapclient
-
-import (
-	"bufio"
-	"crypto/tls"
-	"fmt"
-	"io/ioutil"
-	"net/mail"
-	"strconv"
-	"strings"
-	"time"
-)
-
-//var _ = log.Debug
-
-type Client struct {
-	conn *tls.Conn
-
-	tagCnt uint16 // unused
-
-	name string
-}
-
-type ListItem struct {
-	Attrs []string
-	Delim string
-	Name  string
-}
-
-const (
-	tagPrefix = 'A'
-
-	FlagSeen     = "\\Seen"
-	FlagAnswered = "\\Answered"
-	FlagFlagged  = "\\Flagged"
-	FlagDeleted  = "\\Deleted"
-	FlagDraft    = "\\Draft"
-	FlagRecent   = "\\Recent"
-)
-
-func NewClient(network, addr string) (*Client, error) {
-	conn, err := tls.Dial(network, addr, &tls.Config{ServerName: strings.Split(addr, ":")[0]})
-	if err != nil {
-		return nil, err
-	}
-
-	//consume all response
-	buff := make([]byte, 255)
-	for {
-		n, err := conn.Read(buff)
-		if err != nil {
-			return nil, err
-		}
-		if n != len(buff) {
-			break
-		}
-	}
-
-	return &Client{
-		conn:   conn,
-		tagCnt: 0,
-		name:   time.Now().Format("05.000"),
-	}, nil
-}
-
-func (c *Client) LeakTLSConn() *tls.Conn {
-	return c.conn
-}
-
-func (c *Client) Noop() error {
-	_, err := c.Command("NOOP")
-	return err
-}
-
-func (c *Client) Capability() (capabilities []string, err error) {
-	res, err := c.Command("CAPABILITY")
-	if err != nil {
-		return nil, err
-	}
-	return strings.Split(res, " "), nil
-}
-
-func (c *Client) StartTLS() error {
-	return fmt.Errorf("not implemented")
-}
-
-func (c *Client) Authenticate(mechaname string) error {
-	_, err := c.Command(fmt.Sprintf("AUTHENTICATE %s", mechaname))
-	return err
-}
-
-func (c *Client) Login(username, password string) error {
-	_, err := c.Command(fmt.Sprintf("LOGIN %v %v", username, password))
-	return err
-}
-
-func (c *Client) Select(mailbox string) error {
-	mailbox, err := EncodeModifiedUTF7String(mailbox)
-	if err != nil {
-		return fmt.Errorf("failed to encode mailbox: %v", err)
-	}
-	_, err = c.Command(fmt.Sprintf("SELECT %v", mailbox))
-	return err
-}
-
-func (c *Client) Examine(mailbox string) error {
-	mailbox, err := EncodeModifiedUTF7String(mailbox)
-	if err != nil {
-		return fmt.Errorf("failed to encode mailbox: %v", err)
-	}
-	_, err = c.Command(fmt.Sprintf("EXAMINE %v", mailbox))
-	return err
-}
-
-func (c *Client) Create(mailbox string) error {
-	mailbox, err := EncodeModifiedUTF7String(mailbox)
-	if err != nil {
-		return fmt.Errorf("failed to encode mailbox: %v", err)
-	}
-	_, err = c.Command(fmt.Sprintf("CREATE %v", mailbox))
-	return err
-}
-
-func (c *Client) Delete(mailbox string) error {
-	mailbox, err := EncodeModifiedUTF7String(mailbox)
-	if err != nil {
-		return fmt.Errorf("failed to encode mailbox: %v", err)
-	}
-	_, err = c.Command(fmt.Sprintf("DELETE %v", mailbox))
-	return err
-}
-
-func (c *Client) Rename(mailbox, newname string) error {
-	mailbox, err := EncodeModifiedUTF7String(mailbox)
-	if err != nil {
-		return fmt.Errorf("failed to encode mailbox: %v", err)
-	}
-	_, err = c.Command(fmt.Sprintf("RENAME %v %v", mailbox, newname))
-	return err
-}
-
-func (c *Client) Subscribe(mailbox string) error {
-	mailbox, err := EncodeModifiedUTF7String(mailbox)
-	if err != nil {
-		return fmt.Errorf("failed to encode mailbox: %v", err)
-	}
-	_, err = c.Command(fmt.Sprintf("SUBSCRIBE %v", mailbox))
-	return err
-}
-
-func (c *Client) Unsubscribe(mailbox string) error {
-	mailbox, err := EncodeModifiedUTF7String(mailbox)
-	if err != nil {
-		return fmt.Errorf("failed to encode mailbox: %v", err)
-	}
-	_, err = c.Command(fmt.Sprintf("UNSUBSCRIBE %v", mailbox))
-	return err
-}
-
-func (c *Client) List(reference, mailbox string) ([]ListItem, error) {
-	mailbox, err := EncodeModifiedUTF7String(mailbox)
-	if err != nil {
-		return nil, fmt.Errorf("failed to encode mailbox: %v", err)
-	}
-	res, err := c.Command(fmt.Sprintf("LIST \"%v\" \"%v\"", reference, mailbox))
-	if err != nil {
-		return nil, err
-	}
-
-	items := make([]ListItem, 0, 10)
-
-	s := bufio.NewScanner(strings.NewReader(res))
-	for s.Scan() {
-		line := s.Text()
-		if !strings.HasPrefix(line, "* LIST") {
-			break
-		}
-
-		// attrs
-		posAttrSt := strings.Index(line, "(")
-		posAttrEd := strings.Index(line, ")")
-		// delim
-		posDlmSt := strings.Index(line[posAttrEd+1:], "\"") + posAttrEd + 1
-		posDlmEd := strings.Index(line[posDlmSt+1:], "\"") + posDlmSt + 1
-		// name
-		posNmSt := strings.Index(line[posDlmEd+1:], "\"") + posDlmEd + 1
-		posNmEd := strings.Index(line[posNmSt+1:], "\"") + posNmSt + 1
-
-		name8, err := DecodeModifiedUTF7([]byte(line[posNmSt+1 : posNmEd]))
-		if err != nil {
-			name8 = []byte(line[posNmSt+1 : posNmEd])
-		}
-		items = append(items, ListItem{
-			Attrs: strings.Split(line[posAttrSt+1:posAttrEd], " "),
-			Delim: line[posDlmSt+1 : posDlmEd],
-			Name:  string(name8),
-		})
-	}
-	return items, nil
-}
-
-func (c *Client) LSub(reference, mailbox string) /* hatena    []ListItem,*/ error {
-	return fmt.Errorf("not implemented")
-}
-
-func (c *Client) Status(mailbox string, itemNames []string) (map[string]uint32, error) {
-	mailbox, err := EncodeModifiedUTF7String(mailbox)
-	if err != nil {
-		return nil, fmt.Errorf("failed to encode mailbox: %v", err)
-	}
-	res, err := c.Command(fmt.Sprintf("STATUS \"%v\" (%v)", mailbox, strings.Join(itemNames, " ")))
-	if err != nil {
-		return nil, err
-	}
-
-	s := bufio.NewScanner(strings.NewReader(res))
-	for s.Scan() {
-		line := s.Text()
-		if !strings.HasPrefix(line, "* STATUS") {
-			break
-		}
-
-		posPE := strings.LastIndex(line, ")")
-		if posPE == -1 {
-			return nil, nil
-		}
-		posPS := strings.LastIndex(line, "(")
-		if posPE == -1 {
-			return nil, fmt.Errorf("failed to parse status")
-		}
-
-		st := line[posPS+1 : posPE]
-		sts := strings.Split(st, " ")
-		if len(sts)%2 == 1 {
-			return nil, fmt.Errorf("not paired (last:%v)", sts[len(sts)-1])
-		}
-
-		m := make(map[string]uint32)
-		for i := 0; i < len(sts)/2; i++ {
-			v, err := strconv.ParseUint(sts[i+1], 10, 32)
-			if err != nil {
-				return nil, fmt.Errorf("unexpected value of %v %v", sts[i], sts[i+1])
-			}
-			m[sts[i]] = uint32(v)
-		}
-		return m, nil
-	}
-
-	return nil, nil
-}
-
-func (c *Client) Append(mailbox string, flags []string, message mail.Message) error {
-	mailbox, err := EncodeModifiedUTF7String(mailbox)
-	if err != nil {
-		return fmt.Errorf("failed to encode mailbox: %v", err)
-	}
-
-	addIfMissing(message.Header, "Content-Type", "text/plain; charset=\"utf-8\"")
-	addIfMissing(message.Header, "MIME-Version", "1.0")
-	addIfMissing(message.Header, "Content-Transfer-Encoding", "base64")
-	addIfMissing(message.Header, "Date", time.Now().Format(time.RFC1123Z))
-
-	body, err := ioutil.ReadAll(message.Body)
-	if err != nil {
-		return fmt.Errorf("failed to read body from message: %v", err)
-	}
-
-	contentLines := make([]string, 0, 10)
-	for k, v := range message.Header {
-		contentLines = append(contentLines, k+": "+strings.Join(v, ""))
-	}
-	contentLines = append(contentLines, "")
-	contentLines = append(contentLines, string(body))
-	contentLines = append(contentLines, "")
-	contentLines = append(contentLines, "")
-	contents := strings.Join(contentLines, "\r\n")
-	contentLength := len(contents)
-
-	var flagPart string
-	if len(flags) == 0 {
-		flagPart = ""
-	} else {
-		flagPart = "(" + strings.Join(flags, " ") + ") "
-	}
-
-	//log.Debugln("==================================================")
-	//log.Debugf(os.Stderr, "%v\n", contents)
-	//log.Debugln("==================================================")
-
-	_, err = c.Command(fmt.Sprintf("APPEND \"%v\" %v{%v}", mailbox, flagPart, contentLength))
-	if err != nil {
-		//log.Debugf("err: %v\n", err)
-		return err
-	}
-
-	//log.Debugln("sending contents")
-	_, err = c.Raw("", contents+"\r\n")
-	if err != nil {
-		//log.Debugf("err: %v\n", err)
-		return err
-	}
-	//_, err = c.Command(contents)
-	//if err != nil {
-	//	return err
-	//}
-
-	return nil
-}
-
-func (c *Client) Search(criteria string, optLiteral ...string) ([]uint32, error) {
-	if criteria == "" {
-		criteria = "ALL"
-	}
-
-	var res string
-	var err error
-	if len(optLiteral) == 0 {
-		res, err = c.Command("SEARCH " + criteria)
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		res, err = c.Command(fmt.Sprintf("SEARCH CHARSET UTF-8 %s {%d}", criteria, len(optLiteral[0])))
-		if err != nil {
-			return nil, err
-		}
-		res, err = c.Raw("", optLiteral[0]+"\r\n")
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	s := bufio.NewScanner(strings.NewReader(res))
-	for s.Scan() {
-		line := s.Text()
-		if !strings.HasPrefix(line, "* SEARCH") {
-			break
-		}
-
-		//log.Debugf("line:%q\n", line)
-		idStrs := strings.Split(strings.Trim(line[8:], " "), " ")
-		//log.Debugf("idStrs:%#v\n", idStrs)
-		ids := make([]uint32, 0, len(idStrs))
-		for _, id := range idStrs {
-			if id == "" {
-				break
-			}
-			v, err := strconv.ParseUint(id, 10, 32)
-			if err != nil {
-				return nil, fmt.Errorf("unexpected id %v", id)
-			}
-			ids = append(ids, uint32(v))
-		}
-		return ids, nil
-	}
-
-	return nil, nil
-}
-
-func (c *Client) Fetch(seqSet string, optHeader ...bool) (map[uint32]*mail.Message, error) {
-	var res string
-	var err error
-	header := len(optHeader) != 0 && optHeader[0]
-	if header {
-		res, err = c.Command(fmt.Sprintf("FETCH %v (BODY.PEEK[HEADER])", seqSet))
-	} else {
-		res, err = c.Command(fmt.Sprintf("FETCH %v (BODY.PEEK[])", seqSet))
-	}
-	if err != nil {
-		return nil, err
-	}
-
-	mails := make(map[uint32]*mail.Message)
-
-	s := bufio.NewScanner(strings.NewReader(res))
-	for s.Scan() {
-		line := s.Text()
-
-		// beginning of one of messages
-		if strings.HasPrefix(line, "*") {
-			if strings.Index(line, "FETCH") == -1 {
-				continue
-			}
-
-			// parse sequence
-			posSP1 := strings.Index(line, " ")
-			posSP2 := posSP1 + 1 + strings.Index(line[posSP1+1:], " ")
-			seqStr := line[posSP1+1 : posSP2]
-			seq64, err := strconv.ParseUint(seqStr, 10, 32)
-			if err != nil {
-				return nil, fmt.Errorf("unexpected seq %v (line=%v, sp1=%v, sp2=%v): %v", seqStr, line, posSP1, posSP2, err)
-			}
-			seq := uint32(seq64)
-
-			// parse meessage
-			rawmsg := make([]string, 0, 10)
-			for s.Scan() {
-				line := s.Text()
-
-				if strings.HasPrefix(line, ")") {
-					break // end parsing message
-				}
-
-				rawmsg = append(rawmsg, line)
-			}
-			if header {
-				// empty body makes an EOF error with mail.ReadMessage()
-				rawmsg = append(rawmsg, "")
-			}
-
-			// ROUGH trim last )
-			if len(rawmsg) > 0 {
-				last := rawmsg[len(rawmsg)-1]
-				if len(last) > 0 && last[len(last)-1] == ')' {
-					rawmsg[len(rawmsg)-1] = last[:len(last)-1]
-				}
-			}
-
-			//log.Debug("seq", seq)
-			//log.Debug("rawmsg", rawmsg)
-			r := strings.NewReader(strings.Join(rawmsg, "\r\n"))
-			m, err := mail.ReadMessage(r)
-			if err != nil {
-				return nil, fmt.Errorf("failed to read message (of seq %v): %v", seq, err)
-			}
-
-			mails[seq] = m
-		}
-	}
-
-	return mails, nil
-}
-
-func (c *Client) Store(seqSet, dataItem string, flags []string) error {
-	_, err := c.Command(fmt.Sprintf("STORE %v %v (%s)", seqSet, dataItem, strings.Join(flags, " ")))
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
-func (c *Client) Expunge() error {
-	_, err := c.Command("EXPUNGE")
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
-func (c *Client) Idle() error {
-	_, err := c.Command("IDLE")
-	return err
-}
-
-func (c *Client) IdleWait() error {
-	_, err := c.Command("IDLE")
-	if err != nil {
-		return err
-	}
-
-	// wait for any response
-
-	r := bufio.NewReader(c.conn)
-	//log.Debugf("scanning")
-	buff := make([]byte, 1)
-	r.Read(buff)
-	_, err = r.Discard(r.Buffered())
-	//log.Debugf("finish scanning")
-	//log.Debug()
-
-	return nil
-}
-
-func (c *Client) Done() error {
-	_, err := c.Raw("", "DONE\r\n")
-	return err
-}
-
-func (c *Client) Logout() error {
-	_, err := c.Command("LOGOUT")
-	return err
-}
-
-func (c *Client) Raw(tag, raw string) (string, error) {
-	//log.Debugf("%v C: %v", c.name, raw)
-	if _, err := c.conn.Write([]byte(raw)); err != nil {
-		return "", err
-	}
-
-	//log.Debugln("==========================================")
-	//log.Debug(raw)
-	//log.Debugln("------------------------------------------")
-
-	// receive response and parse it
-	var resSt string
-	var resLastMyMsg string
-	var resMsg string
-	s := bufio.NewScanner(c.conn)
-	//log.Debugf("%v: scanning", c.name)
-	for s.Scan() {
-		//log.Debugf("[%v] s.text()\n", tag)
-		resline := s.Text()
-		//log.Debugf("%v S [%v]: %v", c.name, tag, resline)
-
-		if len(resline) > 0 && resline[0] == '+' {
-			resSt = "+"
-			resLastMyMsg = resline
-			break
-		}
-
-		resMsg += resline + "\r\n"
-
-		if len(resline) == 0 {
-			continue
-		}
-
-		if resline[0] == tagPrefix {
-			if resSt == "" {
-				stcomps := strings.Split(resline, " ")
-				//log.Debugf("status components: %#v\n", stcomps)
-				var st string
-				if len(stcomps) >= 2 {
-					//TAG RESST REMAININGS
-					st = stcomps[1]
-				}
-				//log.Debugf("status: %v\n", st)
-
-				switch st {
-				case "OK":
-				case "NO":
-				case "BAD":
-				case "PREAUTH":
-				case "BYE":
-					//NO fallthrough
-				default:
-					st = ""
-				}
-				resSt = st
-			}
-		}
-
-		if resSt != "" {
-			resLastMyMsg = resline
-			break
-		}
-	}
-	//log.Debugf("%v: finish scanning", c.name)
-	//log.Debug(c.name)
-
-	if err := s.Err(); err != nil {
-		return "", fmt.Errorf("failed to scan result: %v", err)
-	}
-
-	//log.Debugf("resSt:%v, resLastMyMsg:%v", resSt, string(resLastMyMsg))
-	if resSt != "OK" && resSt != "+" {
-		//log.Debugf("%v: not OK nor +: %v", c.name, string(resLastMyMsg))
-		return resMsg, fmt.Errorf("%v", string(resLastMyMsg))
-	}
-	return resMsg, nil
-}
-
-func (c *Client) Command(cmd string) (string, error) {
-	tag := c.makeNewTag()
-	raw := fmt.Sprintf("%v %v\r\n", tag, cmd)
-
-	return c.Raw(tag, raw)
-}
-
-func (c *Client) makeNewTag() string {
-	c.tagCnt = (c.tagCnt + 1) % 1000
-	return fmt.Sprintf("%c%d", tagPrefix, c.tagCnt)
-}
-
-func addIfMissing(m mail.Header, key, value string) {
-	if _, found := m[key]; !found {
-		m[key] = []string{value}
-	}
-}
+package imapclient
+
+import (
+	"bufio"
+	"compress/flate"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//var _ = log.Debug
+
+type Client struct {
+	conn      net.Conn // *tls.Conn unless before STARTTLS
+	r         *bufio.Reader
+	rconn     net.Conn      // the connection r reads, which takes read deadlines; conn if nil
+	partial   string        // a line interrupted by a read deadline, continued by readLine
+	deflate   *flate.Writer // COMPRESS=DEFLATE
+	tlsConfig *tls.Config
+
+	tagCnt uint16 // unused
+
+	caps    []string        // cache of Capability
+	enabled map[string]bool // ENABLEd capabilities
+
+	mailbox *MailboxStatus // selected mailbox
+	connErr error          // the first error of reading or writing the connection
+
+	serverID map[string]string // returned by ID
+
+	notifySubscription bool                         // NOTIFY SubscriptionChange
+	notifyMailboxName  bool                         // NOTIFY MailboxName
+	notifyStatus       map[string]map[string]uint32 // last STATUS of NOTIFY
+	notifyMailboxes    map[string]bool              // known mailboxes and whether subscribed, for NOTIFY
+
+	name string
+}
+
+type ListItem struct {
+	Attrs []string
+	Delim string
+	Name  string
+
+	// ChildInfo are the selection options matched by children, such as "SUBSCRIBED" (LIST-EXTENDED).
+	ChildInfo []string
+	// Status is STATUS of the mailbox if asked by ListOptions.ReturnStatus.
+	Status map[string]uint32
+}
+
+// MailboxStatus is the status of the mailbox reported by SELECT or EXAMINE.
+type MailboxStatus struct {
+	Name           string
+	ReadOnly       bool
+	Flags          []string
+	PermanentFlags []string
+	Exists         uint32
+	Recent         uint32
+	Unseen         uint32 // the first unseen message
+	UIDNext        uint32
+	UIDValidity    uint32
+	HighestModSeq  uint64 // CONDSTORE
+	NoModSeq       bool   // CONDSTORE is not supported by the mailbox
+}
+
+const (
+	tagPrefix = 'A'
+
+	FlagSeen     = "\\Seen"
+	FlagAnswered = "\\Answered"
+	FlagFlagged  = "\\Flagged"
+	FlagDeleted  = "\\Deleted"
+	FlagDraft    = "\\Draft"
+	FlagRecent   = "\\Recent"
+)
+
+// Options are the options of NewClientOptions.
+type Options struct {
+	// ID is sent by ID after connect if not nil and the server supports ID (RFC 2971).
+	// The identification of the server is returned by ServerID.
+	// If the server refuses ID, the client is returned anyway.
+	ID map[string]string
+
+	// StartTLS connects without TLS, and then starts TLS by STARTTLS.
+	StartTLS bool
+	// TLSConfig is the configuration of TLS. If nil, ServerName is taken from addr.
+	TLSConfig *tls.Config
+}
+
+func NewClient(network, addr string) (*Client, error) {
+	return NewClientOptions(network, addr, nil)
+}
+
+// NewClientOptions connects to addr with opts.
+func NewClientOptions(network, addr string, opts *Options) (*Client, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	config := opts.TLSConfig
+	if config == nil {
+		config = &tls.Config{ServerName: strings.Split(addr, ":")[0]}
+	}
+
+	var conn net.Conn
+	var err error
+	if opts.StartTLS {
+		conn, err = net.Dial(network, addr)
+	} else {
+		conn, err = tls.Dial(network, addr, config)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{
+		conn:      conn,
+		r:         bufio.NewReader(conn),
+		tlsConfig: config,
+		tagCnt:    0,
+		name:      time.Now().Format("05.000"),
+	}
+
+	//consume the greeting
+	greeting, err := c.readLine()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if strings.HasPrefix(greeting, "* BYE") {
+		conn.Close()
+		return nil, fmt.Errorf("%v", greeting)
+	}
+
+	if opts.StartTLS {
+		if err := c.StartTLS(); err != nil {
+			c.conn.Close()
+			return nil, err
+		}
+	}
+
+	if opts.ID != nil {
+		// the server may refuse ID, which does not matter
+		if err := c.sendID(opts.ID); err != nil && c.connErr != nil {
+			c.conn.Close()
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// sendID sends ID if the server supports it.
+func (c *Client) sendID(id map[string]string) error {
+	hasID, err := c.HasCapability("ID")
+	if err != nil {
+		return err
+	}
+	if !hasID {
+		return nil
+	}
+	_, err = c.ID(id)
+	return err
+}
+
+// LeakTLSConn returns the TLS connection, or nil before STARTTLS.
+func (c *Client) LeakTLSConn() *tls.Conn {
+	conn, _ := c.conn.(*tls.Conn)
+	return conn
+}
+
+func (c *Client) Noop() error {
+	_, err := c.Command("NOOP")
+	return err
+}
+
+func (c *Client) Capability() (capabilities []string, err error) {
+	res, err := c.Command("CAPABILITY")
+	if err != nil {
+		return nil, err
+	}
+
+	capabilities = make([]string, 0, 10)
+	for _, line := range untaggedLines(res, "CAPABILITY") {
+		capabilities = append(capabilities, strings.Fields(line)[1:]...)
+	}
+	c.caps = capabilities
+	return capabilities, nil
+}
+
+// HasCapability reports whether the server has the capability name (case insensitive).
+// Capabilities are asked once and remembered until Login or Authenticate.
+func (c *Client) HasCapability(name string) (bool, error) {
+	if c.caps == nil {
+		if _, err := c.Capability(); err != nil {
+			return false, err
+		}
+	}
+	for _, capa := range c.caps {
+		if strings.EqualFold(capa, name) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Enable enables capabilities of the server (ENABLE, RFC 5161) and returns the ones the server enabled.
+// The client changes its behavior by the enabled ones:
+//
+//	UTF8=ACCEPT: mailbox names are sent in UTF-8 instead of modified UTF-7.
+//	QRESYNC: VANISHED is asked by FetchChanges.
+func (c *Client) Enable(caps ...string) ([]string, error) {
+	res, err := c.Command("ENABLE " + strings.Join(caps, " "))
+	if err != nil {
+		return nil, err
+	}
+
+	if c.enabled == nil {
+		c.enabled = make(map[string]bool)
+	}
+	enabled := make([]string, 0, len(caps))
+	for _, line := range untaggedLines(res, "ENABLED") {
+		for _, capa := range strings.Fields(line)[1:] {
+			enabled = append(enabled, capa)
+			c.enabled[strings.ToUpper(capa)] = true
+		}
+	}
+	return enabled, nil
+}
+
+// EnableIfSupported enables the capabilities in caps that the server has.
+func (c *Client) EnableIfSupported(caps ...string) ([]string, error) {
+	supported := make([]string, 0, len(caps))
+	for _, capa := range caps {
+		has, err := c.HasCapability(capa)
+		if err != nil {
+			return nil, err
+		}
+		if has {
+			supported = append(supported, capa)
+		}
+	}
+	if len(supported) == 0 {
+		return nil, nil
+	}
+	return c.Enable(supported...)
+}
+
+// Enabled reports whether the capability is enabled by Enable (case insensitive).
+func (c *Client) Enabled(capa string) bool {
+	return c.enabled[strings.ToUpper(capa)]
+}
+
+// StartTLS starts TLS on the connection without TLS (STARTTLS).
+func (c *Client) StartTLS() error {
+	if _, ok := c.conn.(*tls.Conn); ok {
+		return fmt.Errorf("TLS is already started")
+	}
+
+	_, err := c.Command("STARTTLS")
+	if err != nil {
+		return err
+	}
+
+	conn := tls.Client(c.conn, c.tlsConfig)
+	if err := conn.Handshake(); err != nil {
+		return fmt.Errorf("failed to start TLS: %v", err)
+	}
+	// anything buffered before TLS is discarded
+	c.conn = conn
+	c.r = bufio.NewReader(conn)
+	c.caps = nil
+	return nil
+}
+
+func (c *Client) Authenticate(mechaname string) error {
+	_, err := c.Command(fmt.Sprintf("AUTHENTICATE %s", mechaname))
+	if err == nil {
+		c.caps = nil
+	}
+	return err
+}
+
+func (c *Client) Login(username, password string) error {
+	_, err := c.Command(fmt.Sprintf("LOGIN %v %v", username, password))
+	if err == nil {
+		c.caps = nil
+	}
+	return err
+}
+
+func (c *Client) Select(mailbox string) error {
+	_, _, err := c.SelectWith(mailbox, nil)
+	return err
+}
+
+func (c *Client) Examine(mailbox string) error {
+	_, _, err := c.SelectWith(mailbox, &SelectOptions{ReadOnly: true})
+	return err
+}
+
+// SelectOptions are optional parameters of SelectWith.
+type SelectOptions struct {
+	ReadOnly  bool           // EXAMINE instead of SELECT
+	CondStore bool           // (CONDSTORE), reporting HIGHESTMODSEQ
+	QResync   *QResyncParams // (QRESYNC (...)), reporting changes since the last session
+}
+
+// SelectWith selects mailbox and returns its status.
+// Changes are returned if opts.QResync is given, otherwise nil.
+func (c *Client) SelectWith(mailbox string, opts *SelectOptions) (*MailboxStatus, *Changes, error) {
+	if opts == nil {
+		opts = &SelectOptions{}
+	}
+
+	if opts.QResync != nil && !c.Enabled("QRESYNC") {
+		if _, err := c.Enable("QRESYNC"); err != nil {
+			return nil, nil, err
+		}
+		if !c.Enabled("QRESYNC") {
+			return nil, nil, fmt.Errorf("QRESYNC is not enabled")
+		}
+	}
+
+	quoted, err := c.quoteMailbox(mailbox)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode mailbox: %v", err)
+	}
+
+	cmd := "SELECT "
+	if opts.ReadOnly {
+		cmd = "EXAMINE "
+	}
+	cmd += quoted
+	if opts.QResync != nil {
+		cmd += " (QRESYNC " + opts.QResync.String() + ")"
+	} else if opts.CondStore {
+		cmd += " (CONDSTORE)"
+	}
+
+	res, err := c.Command(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	status, err := parseMailboxStatus(mailbox, res)
+	if err != nil {
+		// the mailbox is selected even if its status is broken
+		c.mailbox = &MailboxStatus{Name: mailbox, ReadOnly: opts.ReadOnly}
+		return nil, nil, err
+	}
+	c.mailbox = status
+
+	if opts.QResync == nil {
+		return status, nil, nil
+	}
+	changes, err := parseChanges(res)
+	if err != nil {
+		return nil, nil, err
+	}
+	return status, changes, nil
+}
+
+// Mailbox returns the status of the selected mailbox, or nil if no mailbox is selected.
+func (c *Client) Mailbox() *MailboxStatus {
+	return c.mailbox
+}
+
+// parseMailboxStatus parses the response of SELECT or EXAMINE.
+func parseMailboxStatus(name, res string) (*MailboxStatus, error) {
+	st := &MailboxStatus{Name: name}
+
+	var err error
+	for _, line := range splitResponse(res) {
+		if !strings.HasPrefix(line, "* ") {
+			continue
+		}
+		comps := strings.SplitN(line[2:], " ", 3)
+		if len(comps) < 2 {
+			continue
+		}
+
+		switch {
+		case strings.EqualFold(comps[0], "FLAGS"):
+			fields, err := parseFields(line[len("* FLAGS"):])
+			if err != nil || len(fields) != 1 {
+				return nil, fmt.Errorf("failed to parse %q: %v", line, err)
+			}
+			st.Flags = fieldStrings(fields[0])
+		case strings.EqualFold(comps[1], "EXISTS"):
+			st.Exists, err = fieldUint32(comps[0])
+		case strings.EqualFold(comps[1], "RECENT"):
+			st.Recent, err = fieldUint32(comps[0])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %q: %v", line, err)
+		}
+	}
+
+	if arg, found := responseCode(res, "UNSEEN"); found {
+		st.Unseen, err = fieldUint32(arg)
+	}
+	if arg, found := responseCode(res, "UIDNEXT"); found && err == nil {
+		st.UIDNext, err = fieldUint32(arg)
+	}
+	if arg, found := responseCode(res, "UIDVALIDITY"); found && err == nil {
+		st.UIDValidity, err = fieldUint32(arg)
+	}
+	if arg, found := responseCode(res, "HIGHESTMODSEQ"); found && err == nil {
+		st.HighestModSeq, err = fieldUint64(arg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse status: %v", err)
+	}
+	if arg, found := responseCode(res, "PERMANENTFLAGS"); found {
+		fields, err := parseFields(arg)
+		if err != nil || len(fields) != 1 {
+			return nil, fmt.Errorf("failed to parse PERMANENTFLAGS: %v", err)
+		}
+		st.PermanentFlags = fieldStrings(fields[0])
+	}
+	_, st.NoModSeq = responseCode(res, "NOMODSEQ")
+	_, st.ReadOnly = responseCode(res, "READ-ONLY")
+
+	return st, nil
+}
+
+func (c *Client) Create(mailbox string) error {
+	mailbox, err := c.quoteMailbox(mailbox)
+	if err != nil {
+		return fmt.Errorf("failed to encode mailbox: %v", err)
+	}
+	_, err = c.Command(fmt.Sprintf("CREATE %v", mailbox))
+	return err
+}
+
+func (c *Client) Delete(mailbox string) error {
+	mailbox, err := c.quoteMailbox(mailbox)
+	if err != nil {
+		return fmt.Errorf("failed to encode mailbox: %v", err)
+	}
+	_, err = c.Command(fmt.Sprintf("DELETE %v", mailbox))
+	return err
+}
+
+func (c *Client) Rename(mailbox, newname string) error {
+	mailbox, err := c.quoteMailbox(mailbox)
+	if err != nil {
+		return fmt.Errorf("failed to encode mailbox: %v", err)
+	}
+	newname, err = c.quoteMailbox(newname)
+	if err != nil {
+		return fmt.Errorf("failed to encode mailbox: %v", err)
+	}
+	_, err = c.Command(fmt.Sprintf("RENAME %v %v", mailbox, newname))
+	return err
+}
+
+func (c *Client) Subscribe(mailbox string) error {
+	mailbox, err := c.quoteMailbox(mailbox)
+	if err != nil {
+		return fmt.Errorf("failed to encode mailbox: %v", err)
+	}
+	_, err = c.Command(fmt.Sprintf("SUBSCRIBE %v", mailbox))
+	return err
+}
+
+func (c *Client) Unsubscribe(mailbox string) error {
+	mailbox, err := c.quoteMailbox(mailbox)
+	if err != nil {
+		return fmt.Errorf("failed to encode mailbox: %v", err)
+	}
+	_, err = c.Command(fmt.Sprintf("UNSUBSCRIBE %v", mailbox))
+	return err
+}
+
+func (c *Client) List(reference, mailbox string) ([]ListItem, error) {
+	reference, err := c.quoteMailbox(reference)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode reference: %v", err)
+	}
+	mailbox, err = c.quoteMailbox(mailbox)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode mailbox: %v", err)
+	}
+	res, err := c.Command(fmt.Sprintf("LIST %v %v", reference, mailbox))
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]ListItem, 0, 10)
+	for _, line := range untaggedLines(res, "LIST") {
+		item, _, err := c.parseListLine(line)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// parseListLine parses a LIST or LSUB response ("LIST (attrs) delim name [extended]") into ListItem
+// and the extended data.
+func (c *Client) parseListLine(line string) (ListItem, []interface{}, error) {
+	pos := strings.IndexByte(line, ' ')
+	if pos == -1 {
+		return ListItem{}, nil, fmt.Errorf("failed to parse %q", line)
+	}
+	fields, err := parseFields(line[pos+1:])
+	if err != nil || len(fields) < 3 {
+		return ListItem{}, nil, fmt.Errorf("failed to parse %q: %v", line, err)
+	}
+
+	item := ListItem{
+		Attrs: fieldStrings(fields[0]),
+		Delim: fieldString(fields[1]),
+		Name:  c.decodeMailbox(fieldString(fields[2])),
+	}
+
+	var extended []interface{}
+	if len(fields) > 3 {
+		extended = fieldList(fields[3])
+	}
+	for i := 0; i+1 < len(extended); i += 2 {
+		if strings.EqualFold(fieldString(extended[i]), "CHILDINFO") {
+			item.ChildInfo = fieldStrings(extended[i+1])
+		}
+	}
+	return item, extended, nil
+}
+
+func (c *Client) LSub(reference, mailbox string) ([]ListItem, error) {
+	reference, err := c.quoteMailbox(reference)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode reference: %v", err)
+	}
+	mailbox, err = c.quoteMailbox(mailbox)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode mailbox: %v", err)
+	}
+	res, err := c.Command(fmt.Sprintf("LSUB %v %v", reference, mailbox))
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]ListItem, 0, 10)
+	for _, line := range untaggedLines(res, "LSUB") {
+		item, _, err := c.parseListLine(line)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (c *Client) Status(mailbox string, itemNames []string) (map[string]uint32, error) {
+	mailbox, err := c.quoteMailbox(mailbox)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode mailbox: %v", err)
+	}
+	res, err := c.Command(fmt.Sprintf("STATUS %v (%v)", mailbox, strings.Join(itemNames, " ")))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, line := range untaggedLines(res, "STATUS") {
+		_, m, err := c.parseStatusLine(line)
+		if err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+
+	return nil, nil
+}
+
+// parseStatusLine parses a STATUS response ("STATUS mailbox (name value ...)").
+func (c *Client) parseStatusLine(line string) (string, map[string]uint32, error) {
+	fields, err := parseFields(line[len("STATUS"):])
+	if err != nil || len(fields) != 2 {
+		return "", nil, fmt.Errorf("failed to parse status %q: %v", line, err)
+	}
+
+	mailbox := c.decodeMailbox(fieldString(fields[0]))
+
+	sts := fieldStrings(fields[1])
+	if len(sts)%2 == 1 {
+		return "", nil, fmt.Errorf("not paired (last:%v)", sts[len(sts)-1])
+	}
+
+	m := make(map[string]uint32)
+	for i := 0; i+1 < len(sts); i += 2 {
+		v, err := strconv.ParseUint(sts[i+1], 10, 32)
+		if err != nil {
+			return "", nil, fmt.Errorf("unexpected value of %v %v", sts[i], sts[i+1])
+		}
+		m[sts[i]] = uint32(v)
+	}
+	return mailbox, m, nil
+}
+
+func (c *Client) Append(mailbox string, flags []string, message mail.Message) error {
+	mailbox, err := c.quoteMailbox(mailbox)
+	if err != nil {
+		return fmt.Errorf("failed to encode mailbox: %v", err)
+	}
+
+	addIfMissing(message.Header, "Content-Type", "text/plain; charset=\"utf-8\"")
+	addIfMissing(message.Header, "MIME-Version", "1.0")
+	addIfMissing(message.Header, "Content-Transfer-Encoding", "base64")
+	addIfMissing(message.Header, "Date", time.Now().Format(time.RFC1123Z))
+
+	body, err := ioutil.ReadAll(message.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read body from message: %v", err)
+	}
+
+	contentLines := make([]string, 0, 10)
+	for k, v := range message.Header {
+		contentLines = append(contentLines, k+": "+strings.Join(v, ""))
+	}
+	contentLines = append(contentLines, "")
+	contentLines = append(contentLines, string(body))
+	contentLines = append(contentLines, "")
+	contentLines = append(contentLines, "")
+	contents := strings.Join(contentLines, "\r\n")
+	contentLength := len(contents)
+
+	var flagPart string
+	if len(flags) == 0 {
+		flagPart = ""
+	} else {
+		flagPart = "(" + strings.Join(flags, " ") + ") "
+	}
+
+	//log.Debugln("==================================================")
+	//log.Debugf(os.Stderr, "%v\n", contents)
+	//log.Debugln("==================================================")
+
+	// UTF8 (~{n}) allows UTF-8 headers if UTF8=ACCEPT is enabled (RFC 6855)
+	literal, closing := fmt.Sprintf("{%v}", contentLength), ""
+	if c.Enabled("UTF8=ACCEPT") {
+		literal, closing = fmt.Sprintf("UTF8 (~{%v}", contentLength), ")"
+	} else if isBinaryMessage(message.Header, contents) {
+		// literal8 (~{n}) allows binary contents (RFC 3516)
+		hasBinary, err := c.HasCapability("BINARY")
+		if err != nil {
+			return err
+		}
+		if !hasBinary {
+			return fmt.Errorf("the server does not support BINARY")
+		}
+		literal = fmt.Sprintf("~{%v}", contentLength)
+	}
+
+	// the server may refuse by [OVERQUOTA] before or after the contents
+	_, err = c.Command(fmt.Sprintf("APPEND %v %v%v", mailbox, flagPart, literal))
+	if err != nil {
+		//log.Debugf("err: %v\n", err)
+		return overQuotaError(err)
+	}
+
+	//log.Debugln("sending contents")
+	_, err = c.Raw("", contents+closing+"\r\n")
+	if err != nil {
+		//log.Debugf("err: %v\n", err)
+		return overQuotaError(err)
+	}
+	//_, err = c.Command(contents)
+	//if err != nil {
+	//	return err
+	//}
+
+	return nil
+}
+
+// isBinaryMessage reports whether the message needs literal8,
+// that is, Content-Transfer-Encoding is binary or contents contain NUL.
+func isBinaryMessage(header mail.Header, contents string) bool {
+	if strings.EqualFold(strings.TrimSpace(header.Get("Content-Transfer-Encoding")), "binary") {
+		return true
+	}
+	return strings.IndexByte(contents, 0) != -1
+}
+
+func (c *Client) Search(criteria string, optLiteral ...string) ([]uint32, error) {
+	if criteria == "" {
+		criteria = "ALL"
+	}
+
+	var res string
+	var err error
+	if len(optLiteral) == 0 {
+		res, err = c.Command("SEARCH " + criteria)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		res, err = c.Command(fmt.Sprintf("SEARCH CHARSET UTF-8 %s {%d}", criteria, len(optLiteral[0])))
+		if err != nil {
+			return nil, err
+		}
+		res, err = c.Raw("", optLiteral[0]+"\r\n")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return parseSearchResponse(res)
+}
+
+func parseSearchResponse(res string) ([]uint32, error) {
+	s := bufio.NewScanner(strings.NewReader(res))
+	for s.Scan() {
+		line := s.Text()
+		if !strings.HasPrefix(line, "* SEARCH") {
+			continue
+		}
+
+		//log.Debugf("line:%q\n", line)
+		idStrs := strings.Split(strings.Trim(line[8:], " "), " ")
+		//log.Debugf("idStrs:%#v\n", idStrs)
+		ids := make([]uint32, 0, len(idStrs))
+		for _, id := range idStrs {
+			if id == "" {
+				break
+			}
+			v, err := strconv.ParseUint(id, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("unexpected id %v", id)
+			}
+			ids = append(ids, uint32(v))
+		}
+		return ids, nil
+	}
+
+	return nil, nil
+}
+
+func (c *Client) Fetch(seqSet string, optHeader ...bool) (map[uint32]*mail.Message, error) {
+	header := len(optHeader) != 0 && optHeader[0]
+
+	mails := make(map[uint32]*mail.Message)
+	for _, set := range splitSeqSet(seqSet) {
+		if err := c.fetch(mails, set, header); err != nil {
+			return nil, err
+		}
+	}
+	return mails, nil
+}
+
+func (c *Client) fetch(mails map[uint32]*mail.Message, seqSet string, header bool) error {
+	var res string
+	var err error
+	if header {
+		res, err = c.Command(fmt.Sprintf("FETCH %v (BODY.PEEK[HEADER])", seqSet))
+	} else {
+		res, err = c.Command(fmt.Sprintf("FETCH %v (BODY.PEEK[])", seqSet))
+	}
+	if err != nil {
+		return err
+	}
+
+	s := bufio.NewScanner(strings.NewReader(res))
+	for s.Scan() {
+		line := s.Text()
+
+		// beginning of one of messages
+		if strings.HasPrefix(line, "*") {
+			if strings.Index(line, "FETCH") == -1 {
+				continue
+			}
+
+			// parse sequence
+			posSP1 := strings.Index(line, " ")
+			posSP2 := posSP1 + 1 + strings.Index(line[posSP1+1:], " ")
+			seqStr := line[posSP1+1 : posSP2]
+			seq64, err := strconv.ParseUint(seqStr, 10, 32)
+			if err != nil {
+				return fmt.Errorf("unexpected seq %v (line=%v, sp1=%v, sp2=%v): %v", seqStr, line, posSP1, posSP2, err)
+			}
+			seq := uint32(seq64)
+
+			// parse meessage
+			rawmsg := make([]string, 0, 10)
+			for s.Scan() {
+				line := s.Text()
+
+				if strings.HasPrefix(line, ")") {
+					break // end parsing message
+				}
+
+				rawmsg = append(rawmsg, line)
+			}
+			if header {
+				// empty body makes an EOF error with mail.ReadMessage()
+				rawmsg = append(rawmsg, "")
+			}
+
+			// ROUGH trim last )
+			if len(rawmsg) > 0 {
+				last := rawmsg[len(rawmsg)-1]
+				if len(last) > 0 && last[len(last)-1] == ')' {
+					rawmsg[len(rawmsg)-1] = last[:len(last)-1]
+				}
+			}
+
+			//log.Debug("seq", seq)
+			//log.Debug("rawmsg", rawmsg)
+			r := strings.NewReader(strings.Join(rawmsg, "\r\n"))
+			m, err := mail.ReadMessage(r)
+			if err != nil {
+				return fmt.Errorf("failed to read message (of seq %v): %v", seq, err)
+			}
+
+			mails[seq] = m
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) Store(seqSet, dataItem string, flags []string) error {
+	for _, set := range splitSeqSet(seqSet) {
+		_, err := c.Command(fmt.Sprintf("STORE %v %v (%s)", set, dataItem, strings.Join(flags, " ")))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the selected mailbox, expunging the messages with \Deleted unless it is read-only (CLOSE).
+func (c *Client) Close() error {
+	_, err := c.Command("CLOSE")
+	if err != nil {
+		return err
+	}
+	c.mailbox = nil
+	return nil
+}
+
+// Unselect closes the selected mailbox without expunging (UNSELECT, RFC 3691).
+// If the server lacks UNSELECT, the mailbox is examined and closed instead.
+func (c *Client) Unselect() error {
+	if c.mailbox == nil {
+		return ErrNoMailboxSelected
+	}
+
+	canUnselect, err := c.HasCapability("UNSELECT")
+	if err != nil {
+		return err
+	}
+	if canUnselect {
+		_, err = c.Command("UNSELECT")
+		if err != nil {
+			return err
+		}
+		c.mailbox = nil
+		return nil
+	}
+
+	// CLOSE does not expunge a read-only mailbox
+	if !c.mailbox.ReadOnly {
+		if err := c.Examine(c.mailbox.Name); err != nil {
+			return err
+		}
+	}
+	return c.Close()
+}
+
+func (c *Client) Expunge() error {
+	_, err := c.Command("EXPUNGE")
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// UIDExpunge expunges the messages with \Deleted in uidSet only (UID EXPUNGE, RFC 4315).
+// It returns the sequence numbers of the EXPUNGE responses, in the order reported.
+// Each number is that after the preceding messages were expunged.
+//
+// If QRESYNC is enabled, the server reports VANISHED instead and no sequence numbers are returned.
+func (c *Client) UIDExpunge(uidSet string) ([]uint32, error) {
+	nums := make([]uint32, 0, 4)
+	for _, set := range splitSeqSet(uidSet) {
+		res, err := c.Command("UID EXPUNGE " + set)
+		if err != nil {
+			return nil, err
+		}
+		nums = append(nums, c.parseExpunge(res)...)
+	}
+	return nums, nil
+}
+
+// parseExpunge returns the numbers of "* 3 EXPUNGE" in res.
+func (c *Client) parseExpunge(res string) []uint32 {
+	nums := make([]uint32, 0, 4)
+	for _, line := range splitResponse(res) {
+		if ev, ok := c.parseEvent(line); ok && ev.Type == EventExpunge {
+			nums = append(nums, ev.Num)
+		}
+	}
+	return nums
+}
+
+// IdleWait starts IDLE and waits for any response. Call Done to finish IDLE.
+// Use Idle to know what the response is.
+func (c *Client) IdleWait() error {
+	_, err := c.Command("IDLE")
+	if err != nil {
+		return err
+	}
+
+	// wait for any response
+	_, err = c.readLine()
+	return err
+}
+
+func (c *Client) Done() error {
+	_, err := c.Raw("", "DONE\r\n")
+	return err
+}
+
+func (c *Client) Logout() error {
+	_, err := c.Command("LOGOUT")
+	c.mailbox = nil
+	return err
+}
+
+func (c *Client) Raw(tag, raw string) (string, error) {
+	//log.Debugf("%v C: %v", c.name, raw)
+	if err := c.write(raw); err != nil {
+		return "", err
+	}
+
+	//log.Debugln("==========================================")
+	//log.Debug(raw)
+	//log.Debugln("------------------------------------------")
+
+	res, err := c.readResponse()
+	if tag != "" {
+		raw = strings.TrimSuffix(strings.TrimPrefix(raw, tag+" "), "\r\n")
+	} else {
+		raw = ""
+	}
+	c.trackSelected(raw, res)
+	return res, err
+}
+
+// trackSelected updates the selected mailbox by res, the response to cmd,
+// so that SELECT, EXAMINE, CLOSE and UNSELECT sent by Command or Raw change the state too.
+// cmd is "" for the rest of a command.
+func (c *Client) trackSelected(cmd, res string) {
+	// [CLOSED] (RFC 7162) and BYE close the mailbox, followed by the new one of SELECT if any
+	if _, found := responseCode(res, "CLOSED"); found || len(untaggedLines(res, "BYE")) > 0 {
+		c.mailbox = nil
+	}
+
+	lines := splitResponse(res)
+	if cmd == "" || len(lines) == 0 {
+		return
+	}
+	tagged := strings.SplitN(lines[len(lines)-1], " ", 3)
+	if len(tagged) < 2 || len(tagged[0]) == 0 || tagged[0][0] != tagPrefix {
+		return
+	}
+	st := strings.ToUpper(tagged[1])
+
+	comps := strings.SplitN(cmd, " ", 2)
+	switch verb := strings.ToUpper(comps[0]); verb {
+	case "SELECT", "EXAMINE":
+		switch st {
+		case "OK":
+			var name string
+			if len(comps) == 2 {
+				if fields, err := parseFields(comps[1]); err == nil && len(fields) > 0 {
+					name = c.decodeMailbox(fieldString(fields[0]))
+				}
+			}
+			status, err := parseMailboxStatus(name, res)
+			if err != nil {
+				status = &MailboxStatus{Name: name}
+			}
+			status.ReadOnly = status.ReadOnly || verb == "EXAMINE"
+			c.mailbox = status
+		case "NO":
+			// a failed SELECT leaves no mailbox selected
+			c.mailbox = nil
+		}
+	case "CLOSE", "UNSELECT":
+		if st == "OK" {
+			c.mailbox = nil
+		}
+	}
+}
+
+// readResponse reads responses until a tagged response or a continuation request.
+func (c *Client) readResponse() (string, error) {
+	var resSt string
+	var resLastMyMsg string
+	var resMsg string
+	//log.Debugf("%v: scanning", c.name)
+	for {
+		resline, err := c.readLine()
+		if err != nil {
+			return "", fmt.Errorf("failed to scan result: %v", err)
+		}
+		//log.Debugf("%v S: %v", c.name, resline)
+
+		if len(resline) > 0 && resline[0] == '+' {
+			resSt = "+"
+			resLastMyMsg = resline
+			break
+		}
+
+		resMsg += resline + "\r\n"
+
+		if len(resline) == 0 {
+			continue
+		}
+
+		if resline[0] == tagPrefix {
+			if resSt == "" {
+				stcomps := strings.Split(resline, " ")
+				//log.Debugf("status components: %#v\n", stcomps)
+				var st string
+				if len(stcomps) >= 2 {
+					//TAG RESST REMAININGS
+					st = stcomps[1]
+				}
+				//log.Debugf("status: %v\n", st)
+
+				switch st {
+				case "OK":
+				case "NO":
+				case "BAD":
+				case "PREAUTH":
+				case "BYE":
+					//NO fallthrough
+				default:
+					st = ""
+				}
+				resSt = st
+			}
+		}
+
+		if resSt != "" {
+			resLastMyMsg = resline
+			break
+		}
+	}
+	//log.Debugf("%v: finish scanning", c.name)
+	//log.Debug(c.name)
+
+	//log.Debugf("resSt:%v, resLastMyMsg:%v", resSt, string(resLastMyMsg))
+	if resSt != "OK" && resSt != "+" {
+		//log.Debugf("%v: not OK nor +: %v", c.name, string(resLastMyMsg))
+		return resMsg, fmt.Errorf("%v", string(resLastMyMsg))
+	}
+	return resMsg, nil
+}
+
+// ErrNoMailboxSelected is returned by the commands of the selected state, such as FETCH, when no mailbox is selected.
+var ErrNoMailboxSelected = errors.New("no mailbox selected")
+
+// selectedStateCommands are the commands valid only in the selected state.
+var selectedStateCommands = map[string]bool{
+	"CHECK":    true,
+	"CLOSE":    true,
+	"UNSELECT": true,
+	"EXPUNGE":  true,
+	"SEARCH":   true,
+	"FETCH":    true,
+	"STORE":    true,
+	"COPY":     true,
+	"MOVE":     true,
+	"SORT":     true,
+	"THREAD":   true,
+	"UID":      true,
+}
+
+// Command sends cmd with a new tag and returns the response.
+// A command of the selected state fails with ErrNoMailboxSelected if no mailbox is selected.
+// SELECT, EXAMINE, CLOSE and UNSELECT sent by Command or Raw change the selected mailbox as well.
+func (c *Client) Command(cmd string) (string, error) {
+	if c.mailbox == nil {
+		verb := strings.ToUpper(strings.SplitN(cmd, " ", 2)[0])
+		if selectedStateCommands[verb] {
+			return "", ErrNoMailboxSelected
+		}
+	}
+
+	tag := c.makeNewTag()
+	raw := fmt.Sprintf("%v %v\r\n", tag, cmd)
+
+	return c.Raw(tag, raw)
+}
+
+// commandLiterals sends lc, waiting for the continuation request before each literal.
+func (c *Client) commandLiterals(lc *literalCommand) (string, error) {
+	if len(lc.literals) == 0 {
+		return c.Command(lc.parts[0])
+	}
+
+	res, err := c.Command(fmt.Sprintf("%s{%d}", lc.parts[0], len(lc.literals[0])))
+	if err != nil {
+		return res, err
+	}
+	for i, lit := range lc.literals {
+		next := lc.parts[i+1]
+		if i+1 < len(lc.literals) {
+			next += fmt.Sprintf("{%d}", len(lc.literals[i+1]))
+		}
+		res, err = c.Raw("", lit+next+"\r\n")
+		if err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+// quoteMailbox encodes a mailbox name to be sent, as a quoted string.
+// It is modified UTF-7, or UTF-8 as it is if UTF8=ACCEPT is enabled (RFC 6855).
+func (c *Client) quoteMailbox(name string) (string, error) {
+	if c.Enabled("UTF8=ACCEPT") {
+		return quoteString(name), nil
+	}
+	encoded, err := EncodeModifiedUTF7String(name)
+	if err != nil {
+		return "", err
+	}
+	return quoteString(encoded), nil
+}
+
+// decodeMailbox decodes a mailbox name received.
+// A name that is not modified UTF-7 is returned as it is.
+func (c *Client) decodeMailbox(name string) string {
+	if c.Enabled("UTF8=ACCEPT") {
+		return name
+	}
+	decoded, err := DecodeModifiedUTF7String(name)
+	if err != nil {
+		return name
+	}
+	return decoded
+}
+
+func (c *Client) write(raw string) error {
+	var err error
+	if c.deflate != nil {
+		_, err = c.deflate.Write([]byte(raw))
+		if err == nil {
+			err = c.deflate.Flush()
+		}
+	} else {
+		_, err = c.conn.Write([]byte(raw))
+	}
+	if err != nil && c.connErr == nil {
+		c.connErr = err
+	}
+	return err
+}
+
+// readLine reads a response line without CRLF.
+// A literal at the end of a line is read with the rest of the line,
+// so the line may contain CRLFs of the literal.
+//
+// If a read deadline interrupts it, the line read so far is kept for the next call,
+// and the error is not recorded as connErr.
+func (c *Client) readLine() (string, error) {
+	line := c.partial
+	c.partial = ""
+	for {
+		part, err := c.r.ReadString('\n')
+		if err != nil {
+			if isTimeout(err) {
+				c.partial = line + part
+				return "", err
+			}
+			if c.connErr == nil {
+				c.connErr = err
+			}
+			return "", err
+		}
+		part = strings.TrimRight(part, "\r\n")
+		line += part
+
+		n, found := literalLength(line)
+		if !found {
+			return line, nil
+		}
+
+		lit := make([]byte, n)
+		if _, err := io.ReadFull(c.r, lit); err != nil {
+			if c.connErr == nil {
+				c.connErr = err
+			}
+			return "", err
+		}
+		line += "\r\n" + string(lit)
+	}
+}
+
+// setReadDeadline sets the read deadline of the connection c.r reads.
+func (c *Client) setReadDeadline(t time.Time) error {
+	if c.rconn != nil {
+		return c.rconn.SetReadDeadline(t)
+	}
+	return c.conn.SetReadDeadline(t)
+}
+
+// isTimeout reports whether err is caused by a deadline.
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// literalLength returns n of {n} (or ~{n}) at the end of line.
+func literalLength(line string) (int, bool) {
+	if !strings.HasSuffix(line, "}") {
+		return 0, false
+	}
+	posSt := strings.LastIndexByte(line, '{')
+	if posSt == -1 {
+		return 0, false
+	}
+	n, err := strconv.Atoi(line[posSt+1 : len(line)-1])
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// pipeline sends cmds at once and then reads their responses.
+// errs[i] is the error of cmds[i]; err is set if the connection fails.
+func (c *Client) pipeline(cmds []string) (responses []string, errs []error, err error) {
+	var raw string
+	for _, cmd := range cmds {
+		raw += fmt.Sprintf("%v %v\r\n", c.makeNewTag(), cmd)
+	}
+	if err := c.write(raw); err != nil {
+		return nil, nil, err
+	}
+
+	responses = make([]string, len(cmds))
+	errs = make([]error, len(cmds))
+	for i := range cmds {
+		responses[i], errs[i] = c.readResponse()
+		if errs[i] != nil && responses[i] == "" {
+			return nil, nil, errs[i] // connection error
+		}
+		c.trackSelected(cmds[i], responses[i])
+	}
+	return responses, errs, nil
+}
+
+func (c *Client) makeNewTag() string {
+	c.tagCnt = (c.tagCnt + 1) % 1000
+	return fmt.Sprintf("%c%d", tagPrefix, c.tagCnt)
+}
+
+func addIfMissing(m mail.Header, key, value string) {
+	if _, found := m[key]; !found {
+		m[key] = []string{value}
+	}
+}
+
+// literalCommand is a command line that contains synchronizing literals.
+// parts[i] precedes literals[i], and the last part follows the last literal.
+type literalCommand struct {
+	parts    []string
+	literals []string
+}
+
+func newLiteralCommand(s string) *literalCommand {
+	return &literalCommand{parts: []string{s}}
+}
+
+func (lc *literalCommand) text(s string) {
+	lc.parts[len(lc.parts)-1] += s
+}
+
+func (lc *literalCommand) literal(s string) {
+	lc.literals = append(lc.literals, s)
+	lc.parts = append(lc.parts, "")
+}
+
+// astring appends s as a quoted string, or as a literal if s can not be quoted.
+func (lc *literalCommand) astring(s string) {
+	if !isASCII(s) || strings.ContainsAny(s, "\r\n") {
+		lc.literal(s)
+		return
+	}
+	lc.text(quoteString(s))
+}
+
+func (lc *literalCommand) String() string {
+	var b strings.Builder
+	for i, p := range lc.parts {
+		b.WriteString(p)
+		if i < len(lc.literals) {
+			fmt.Fprintf(&b, "{%d}\r\n%s", len(lc.literals[i]), lc.literals[i])
+		}
+	}
+	return b.String()
+}
+
+func quoteString(s string) string {
+	s = strings.Replace(s, "\\", "\\\\", -1)
+	s = strings.Replace(s, "\"", "\\\"", -1)
+	return "\"" + s + "\""
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
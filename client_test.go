@@ -1,125 +1,471 @@
-package imapclient
-
-import (
-	//"encoding/base64"
-
-	"io/ioutil"
-	"os"
-	"strings"
-	"testing"
-)
-
-/*
-* env IMAP_USER
-* enc IMAP_PASS
-* */
-
-func TestConnect(t *testing.T) {
-	c, err := NewClient("tcp", "imap.gmail.com:993")
-	if err != nil {
-		t.Errorf("NewClient %v\n", err)
-	}
-
-	//A1
-	response, err := c.Command("hoge")
-	if err == nil || !strings.HasPrefix(string(response), "A1 BAD Unknown command") {
-		t.Errorf("Command(hoge) err:%v res:%v\n", err, string(response))
-	}
-
-	//A2
-	response, err = c.Command("NOOP")
-	if err != nil || !strings.HasPrefix(response, "A2 OK") {
-		t.Errorf("Command(NOOP) err:%v res:%v\n", err, string(response))
-	}
-
-	//A3
-	response, err = c.Command("LOGIN " + os.Getenv("IMAP_USER") + " hoge")
-	if err == nil || !strings.HasPrefix(response, "A3 NO") {
-		t.Errorf("Command(LOGIN) err:%v res:%#v\n", err, string(response))
-	}
-
-	/*
-		response, err = c.Command("LOGIN " + os.Getenv("IMAP_USER") + " " + os.Getenv("IMAP_PASS"))
-		if err != nil || !strings.Contains(response, "A4 OK") {
-			t.Errorf("Command(LOGIN2nd) err:%v res:%#v\n", err, string(response))
-		}
-	*/
-	err = c.Login(os.Getenv("IMAP_USER"), os.Getenv("IMAP_PASS"))
-	if err != nil {
-		t.Errorf("Login err:%v\n", err)
-	}
-
-	items, err := c.List("", "*")
-	items = items
-	if err != nil {
-		t.Errorf("List err:%v\n", err)
-	} else {
-		//for i, v := range items {
-		//	fmt.Fprintf(os.Stderr, "Item %v %#v\n", i, v)
-		//}
-	}
-
-	items, err = c.List("", "*pom*")
-	if err != nil {
-		t.Errorf("List err:%v\n", err)
-	} else {
-		//for i, v := range items {
-		//	fmt.Fprintf(os.Stderr, "Item %v %#v\n", i, v)
-		//}
-	}
-
-	st, err := c.Status("Notes/pomera_sync", []string{"MESSAGES"})
-	st = st
-	if err != nil {
-		t.Errorf("List err:%v\n", err)
-	} else {
-		//for k, v := range st {
-		//	fmt.Fprintf(os.Stderr, "Item %v %v\n", k, v)
-		//}
-	}
-
-	err = c.Select("Notes/pomera_sync")
-	if err != nil {
-		t.Errorf("List err:%v\n", err)
-	}
-
-	ids, err := c.Search("")
-	if err != nil {
-		t.Errorf("List err:%v\n", err)
-	} else if len(ids) < 2 {
-		t.Errorf("Fetch len:%v\n", len(ids))
-	} else {
-		//for i, id := range ids {
-		//	fmt.Fprintf(os.Stderr, "Item %v %v\n", i, id)
-		//}
-	}
-
-	mm, err := c.Fetch("1:2,4")
-	if err != nil {
-		t.Errorf("Fetch err:%v\n", err)
-	} else {
-		for _, m := range mm {
-			dm, err := DecodeMailMessage(m)
-			if err != nil {
-				t.Errorf("DecodeMailMessage error: %v", err)
-			} else if len(dm) == 0 {
-				t.Errorf("no DecodeMailMessages")
-			} else {
-				//fmt.Fprintf(os.Stderr, "header[%#v]\n", dm[0].Header)
-
-				_ /*body*/, err := ioutil.ReadAll(dm[0].Body)
-				if err != nil {
-					t.Errorf("DecodeMailMessage error: %v", err)
-				} else {
-					//fmt.Fprintf(os.Stderr, "body[%#v]\n", string(body))
-				}
-			}
-		}
-	}
-
-	c.Noop()
-	c.Logout()
-
-	//A6
-
-}
+package imapclient
+
+import (
+	//"encoding/base64"
+
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net/mail"
+	"os"
+	"strings"
+	"testing"
+)
+
+/*
+* env IMAP_USER
+* enc IMAP_PASS
+* */
+
+func TestConnect(t *testing.T) {
+	c, err := NewClient("tcp", "imap.gmail.com:993")
+	if err != nil {
+		t.Errorf("NewClient %v\n", err)
+	}
+
+	//A1
+	response, err := c.Command("hoge")
+	if err == nil || !strings.HasPrefix(string(response), "A1 BAD Unknown command") {
+		t.Errorf("Command(hoge) err:%v res:%v\n", err, string(response))
+	}
+
+	//A2
+	response, err = c.Command("NOOP")
+	if err != nil || !strings.HasPrefix(response, "A2 OK") {
+		t.Errorf("Command(NOOP) err:%v res:%v\n", err, string(response))
+	}
+
+	//A3
+	response, err = c.Command("LOGIN " + os.Getenv("IMAP_USER") + " hoge")
+	if err == nil || !strings.HasPrefix(response, "A3 NO") {
+		t.Errorf("Command(LOGIN) err:%v res:%#v\n", err, string(response))
+	}
+
+	/*
+		response, err = c.Command("LOGIN " + os.Getenv("IMAP_USER") + " " + os.Getenv("IMAP_PASS"))
+		if err != nil || !strings.Contains(response, "A4 OK") {
+			t.Errorf("Command(LOGIN2nd) err:%v res:%#v\n", err, string(response))
+		}
+	*/
+	err = c.Login(os.Getenv("IMAP_USER"), os.Getenv("IMAP_PASS"))
+	if err != nil {
+		t.Errorf("Login err:%v\n", err)
+	}
+
+	items, err := c.List("", "*")
+	items = items
+	if err != nil {
+		t.Errorf("List err:%v\n", err)
+	} else {
+		//for i, v := range items {
+		//	fmt.Fprintf(os.Stderr, "Item %v %#v\n", i, v)
+		//}
+	}
+
+	items, err = c.List("", "*pom*")
+	if err != nil {
+		t.Errorf("List err:%v\n", err)
+	} else {
+		//for i, v := range items {
+		//	fmt.Fprintf(os.Stderr, "Item %v %#v\n", i, v)
+		//}
+	}
+
+	st, err := c.Status("Notes/pomera_sync", []string{"MESSAGES"})
+	st = st
+	if err != nil {
+		t.Errorf("List err:%v\n", err)
+	} else {
+		//for k, v := range st {
+		//	fmt.Fprintf(os.Stderr, "Item %v %v\n", k, v)
+		//}
+	}
+
+	err = c.Select("Notes/pomera_sync")
+	if err != nil {
+		t.Errorf("List err:%v\n", err)
+	}
+
+	ids, err := c.Search("")
+	if err != nil {
+		t.Errorf("List err:%v\n", err)
+	} else if len(ids) < 2 {
+		t.Errorf("Fetch len:%v\n", len(ids))
+	} else {
+		//for i, id := range ids {
+		//	fmt.Fprintf(os.Stderr, "Item %v %v\n", i, id)
+		//}
+	}
+
+	mm, err := c.Fetch("1:2,4")
+	if err != nil {
+		t.Errorf("Fetch err:%v\n", err)
+	} else {
+		for _, m := range mm {
+			dm, err := DecodeMailMessage(m)
+			if err != nil {
+				t.Errorf("DecodeMailMessage error: %v", err)
+			} else if len(dm) == 0 {
+				t.Errorf("no DecodeMailMessages")
+			} else {
+				//fmt.Fprintf(os.Stderr, "header[%#v]\n", dm[0].Header)
+
+				_ /*body*/, err := ioutil.ReadAll(dm[0].Body)
+				if err != nil {
+					t.Errorf("DecodeMailMessage error: %v", err)
+				} else {
+					//fmt.Fprintf(os.Stderr, "body[%#v]\n", string(body))
+				}
+			}
+		}
+	}
+
+	c.Noop()
+	c.Logout()
+
+	//A6
+
+}
+
+func TestQuoteMailbox(t *testing.T) {
+	cases := []struct {
+		name    string
+		enabled bool
+		expect  string
+	}{
+		{"INBOX", false, `"INBOX"`},
+		{"日本語", false, `"&ZeVnLIqe-"`},
+		{"a&b", false, `"a&-b"`},
+		{`a"b`, false, `"a\"b"`},
+		{"INBOX", true, `"INBOX"`},
+		{"日本語", true, `"日本語"`},
+		{"a&b", true, `"a&b"`},
+	}
+
+	for i, cs := range cases {
+		c := &Client{}
+		if cs.enabled {
+			c.enabled = map[string]bool{"UTF8=ACCEPT": true}
+		}
+		quoted, err := c.quoteMailbox(cs.name)
+		if err != nil {
+			t.Errorf("%v: quoteMailbox: %v", i, err)
+		} else if quoted != cs.expect {
+			t.Errorf("%v: quoteMailbox %q, expected %q", i, quoted, cs.expect)
+		}
+	}
+}
+
+func TestEnableUTF8Accept(t *testing.T) {
+	c, done := newFakeServer(t, func(s *fakeServer) {
+		s.expect(`A1 CREATE "&ZeVnLIqe-"`)
+		s.send("A1 OK CREATE completed")
+		s.expect(`A2 ENABLE UTF8=ACCEPT`)
+		s.send("* ENABLED UTF8=ACCEPT", "A2 OK ENABLE completed")
+		s.expect(`A3 CREATE "日本語"`)
+		s.send("A3 OK CREATE completed")
+	})
+
+	if err := c.Create("日本語"); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if enabled, err := c.Enable("UTF8=ACCEPT"); err != nil || len(enabled) != 1 || !c.Enabled("UTF8=ACCEPT") {
+		t.Fatalf("Enable: %v %v", enabled, err)
+	}
+	if err := c.Create("日本語"); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	<-done
+}
+
+func TestDecodeMailbox(t *testing.T) {
+	cases := []struct {
+		name    string
+		enabled bool
+		expect  string
+	}{
+		{"&ZeVnLIqe-", false, "日本語"},
+		{"a&-b", false, "a&b"},
+		{"Mail/&ZeVnLIqe-/x", false, "Mail/日本語/x"},
+		{"&ZeVnLIqe-", true, "&ZeVnLIqe-"},
+		{"日本語", true, "日本語"},
+	}
+
+	for i, cs := range cases {
+		c := &Client{}
+		if cs.enabled {
+			c.enabled = map[string]bool{"UTF8=ACCEPT": true}
+		}
+		if name := c.decodeMailbox(cs.name); name != cs.expect {
+			t.Errorf("%v: decodeMailbox %q, expected %q", i, name, cs.expect)
+		}
+	}
+}
+
+func TestAppendUTF8(t *testing.T) {
+	var contents string
+	c, done := newFakeServer(t, func(s *fakeServer) {
+		line, _ := s.r.ReadString('\n')
+		line = strings.TrimSuffix(line, "\r\n")
+		prefix := `A1 APPEND "日本語" (\Seen) UTF8 (~{`
+		n, found := literalLength(line)
+		if !strings.HasPrefix(line, prefix) || !found {
+			t.Errorf("server: got %q, expected %q", line, prefix+"n}")
+			return
+		}
+		s.send("+ Ready")
+		contents = s.read(n)
+		s.expect(")")
+		s.send("A1 OK APPEND completed")
+	})
+	c.enabled = map[string]bool{"UTF8=ACCEPT": true}
+
+	msg := mail.Message{
+		Header: mail.Header{"Subject": {"件名"}, "Content-Transfer-Encoding": {"8bit"}},
+		Body:   strings.NewReader("本文"),
+	}
+	if err := c.Append("日本語", []string{FlagSeen}, msg); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	<-done
+
+	if !strings.Contains(contents, "Subject: 件名\r\n") || !strings.HasSuffix(contents, "\r\n\r\n本文\r\n\r\n") {
+		t.Errorf("unexpected contents %q", contents)
+	}
+}
+
+func TestCommandNoMailboxSelected(t *testing.T) {
+	cases := []struct {
+		cmd    string
+		expect bool // ErrNoMailboxSelected
+	}{
+		{"FETCH 1 (FLAGS)", true},
+		{"fetch 1 (FLAGS)", true},
+		{"UID FETCH 1 (FLAGS)", true},
+		{"uid store 1 +FLAGS (\\Seen)", true},
+		{"UID EXPUNGE 1", true},
+		{"SEARCH ALL", true},
+		{"CLOSE", true},
+		{"UNSELECT", true},
+		{"NOOP", false},
+		{`LIST "" "*"`, false},
+		{`STATUS "INBOX" (MESSAGES)`, false},
+	}
+
+	c, done := newFakeServer(t, func(s *fakeServer) {
+		n := 0
+		for _, cs := range cases {
+			if !cs.expect {
+				n++
+				s.expect(fmt.Sprintf("A%v %v", n, cs.cmd))
+				s.send(fmt.Sprintf("A%v OK done", n))
+			}
+		}
+	})
+
+	for i, cs := range cases {
+		_, err := c.Command(cs.cmd)
+		if cs.expect && err != ErrNoMailboxSelected {
+			t.Errorf("%v: Command(%q) %v, expected %v", i, cs.cmd, err, ErrNoMailboxSelected)
+		} else if !cs.expect && err != nil {
+			t.Errorf("%v: Command(%q): %v", i, cs.cmd, err)
+		}
+	}
+	<-done
+}
+
+func TestSelectState(t *testing.T) {
+	c, done := newFakeServer(t, func(s *fakeServer) {
+		s.expect(`A1 SELECT "INBOX"`)
+		s.send("* 3 EXISTS", "* OK [UIDVALIDITY 1] UIDs valid", "A1 OK [READ-WRITE] SELECT completed")
+		s.expect("A2 CLOSE")
+		s.send("A2 OK CLOSE completed")
+
+		// a broken status after OK still selects
+		s.expect(`A3 EXAMINE "Sent"`)
+		s.send("* x EXISTS", "A3 OK [READ-ONLY] EXAMINE completed")
+
+		// a failed SELECT leaves no mailbox selected
+		s.expect(`A4 SELECT "Gone"`)
+		s.send("A4 NO Mailbox doesn't exist")
+	})
+
+	if err := c.Select("INBOX"); err != nil {
+		t.Fatalf("Select: %v", err)
+	}
+	if mbox := c.Mailbox(); mbox == nil || mbox.Name != "INBOX" || mbox.Exists != 3 || mbox.ReadOnly {
+		t.Errorf("Mailbox %+v after SELECT", mbox)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if mbox := c.Mailbox(); mbox != nil {
+		t.Errorf("Mailbox %+v after CLOSE, expected nil", mbox)
+	}
+
+	if err := c.Examine("Sent"); err == nil {
+		t.Errorf("Examine succeeded, expected an error of the status")
+	}
+	if mbox := c.Mailbox(); mbox == nil || mbox.Name != "Sent" || !mbox.ReadOnly {
+		t.Errorf("Mailbox %+v after a broken EXAMINE, expected Sent read-only", mbox)
+	}
+
+	if err := c.Select("Gone"); err == nil {
+		t.Errorf("Select succeeded, expected an error")
+	}
+	if mbox := c.Mailbox(); mbox != nil {
+		t.Errorf("Mailbox %+v after a failed SELECT, expected nil", mbox)
+	}
+	<-done
+}
+
+func TestUnselect(t *testing.T) {
+	cases := []struct {
+		caps     []string
+		readOnly bool
+		expect   []string // the commands sent
+	}{
+		{[]string{"IMAP4rev1", "UNSELECT"}, false, []string{"UNSELECT"}},
+		{[]string{"IMAP4rev1"}, false, []string{`EXAMINE "INBOX"`, "CLOSE"}},
+		{[]string{"IMAP4rev1"}, true, []string{"CLOSE"}},
+	}
+
+	for i, cs := range cases {
+		c, done := newFakeServer(t, func(s *fakeServer) {
+			for n, cmd := range cs.expect {
+				s.expect(fmt.Sprintf("A%v %v", n+1, cmd))
+				s.send(fmt.Sprintf("A%v OK done", n+1))
+			}
+		})
+		c.caps = cs.caps
+		c.mailbox = &MailboxStatus{Name: "INBOX", ReadOnly: cs.readOnly}
+
+		if err := c.Unselect(); err != nil {
+			t.Errorf("%v: Unselect: %v", i, err)
+		}
+		if mbox := c.Mailbox(); mbox != nil {
+			t.Errorf("%v: Mailbox %+v after Unselect, expected nil", i, mbox)
+		}
+		<-done
+
+		if err := c.Unselect(); err != ErrNoMailboxSelected {
+			t.Errorf("%v: Unselect again %v, expected %v", i, err, ErrNoMailboxSelected)
+		}
+	}
+}
+
+func TestSelectedStateByCommand(t *testing.T) {
+	cases := []struct {
+		selected bool   // INBOX is selected before cmd
+		cmd      string // sent by Command
+		res      []string
+		expect   string // the selected mailbox, or "" if none
+		readOnly bool
+	}{
+		{false, `SELECT "INBOX"`, []string{"* 3 EXISTS", "A1 OK [READ-WRITE] SELECT completed"}, "INBOX", false},
+		{false, "examine Sent", []string{"* 3 EXISTS", "A1 OK EXAMINE completed"}, "Sent", true},
+		{true, `SELECT "Sent"`, []string{"* OK [CLOSED] Previous mailbox closed", "* 3 EXISTS", "A1 OK SELECT completed"}, "Sent", false},
+		{true, `SELECT "Nonexistent"`, []string{"A1 NO Mailbox does not exist"}, "", false},
+		{true, "SELECT", []string{"A1 BAD Missing argument"}, "INBOX", false},
+		{true, "CLOSE", []string{"A1 OK CLOSE completed"}, "", false},
+		{true, "UNSELECT", []string{"A1 OK UNSELECT completed"}, "", false},
+		{true, "NOOP", []string{"* OK [CLOSED] Mailbox deleted", "A1 OK NOOP completed"}, "", false},
+		{true, "NOOP", []string{"* BYE Shutting down", "A1 OK NOOP completed"}, "", false},
+	}
+
+	for i, cs := range cases {
+		c, done := newFakeServer(t, func(s *fakeServer) {
+			s.expect("A1 " + cs.cmd)
+			s.send(cs.res...)
+		})
+		if cs.selected {
+			c.mailbox = &MailboxStatus{Name: "INBOX"}
+		}
+
+		c.Command(cs.cmd)
+		<-done
+
+		switch mbox := c.Mailbox(); {
+		case cs.expect == "" && mbox != nil:
+			t.Errorf("%v: Mailbox %+v after %q, expected nil", i, mbox, cs.cmd)
+		case cs.expect != "" && (mbox == nil || mbox.Name != cs.expect || mbox.ReadOnly != cs.readOnly):
+			t.Errorf("%v: Mailbox %+v after %q, expected %v (read-only=%v)", i, mbox, cs.cmd, cs.expect, cs.readOnly)
+		}
+	}
+
+	// FETCH is allowed after SELECT by Command
+	c, done := newFakeServer(t, func(s *fakeServer) {
+		s.expect(`A1 SELECT "INBOX"`)
+		s.send("* 1 EXISTS", "A1 OK SELECT completed")
+		s.expect("A2 FETCH 1 (FLAGS)")
+		s.send("* 1 FETCH (FLAGS (\\Seen))", "A2 OK FETCH completed")
+	})
+	if _, err := c.Command(`SELECT "INBOX"`); err != nil {
+		t.Fatalf("SELECT: %v", err)
+	}
+	if _, err := c.Command("FETCH 1 (FLAGS)"); err != nil {
+		t.Errorf("FETCH: %v", err)
+	}
+	<-done
+}
+
+func TestUIDExpunge(t *testing.T) {
+	c, done := newFakeServer(t, func(s *fakeServer) {
+		s.expect("A1 UID EXPUNGE 3000:3002")
+		s.send("* 3 EXPUNGE", "* 3 EXPUNGE", "* 5 FETCH (FLAGS (\\Seen))", "* 3 expunge", "* 4 EXISTS", "A1 OK UID EXPUNGE completed")
+	})
+	c.mailbox = &MailboxStatus{Name: "INBOX"}
+
+	nums, err := c.UIDExpunge("3000:3002")
+	if err != nil {
+		t.Fatalf("UIDExpunge: %v", err)
+	}
+	<-done
+
+	if s, expect := fmt.Sprint(nums), "[3 3 3]"; s != expect {
+		t.Errorf("UIDExpunge %v, expected %v", s, expect)
+	}
+}
+
+func TestStartTLS(t *testing.T) {
+	config := testTLSConfig(t)
+	c, done := newFakeServer(t, func(s *fakeServer) {
+		s.expect("A1 STARTTLS")
+		// a response injected before TLS must not be read as a response within TLS
+		s.send("A1 OK Begin TLS negotiation now\r\n* 1 EXISTS")
+
+		conn := tls.Server(s.conn, config)
+		if err := conn.Handshake(); err != nil {
+			t.Errorf("server: Handshake: %v", err)
+			return
+		}
+		s.conn = conn
+		s.r = bufio.NewReader(conn)
+
+		s.expect("A2 NOOP")
+		s.send("A2 OK NOOP completed")
+	})
+	c.tlsConfig = &tls.Config{InsecureSkipVerify: true}
+	c.caps = []string{"IMAP4rev1", "STARTTLS"}
+
+	if err := c.StartTLS(); err != nil {
+		t.Fatalf("StartTLS: %v", err)
+	}
+	if c.caps != nil {
+		t.Errorf("capabilities %v after StartTLS, expected to be asked again", c.caps)
+	}
+
+	res, err := c.Command("NOOP")
+	if err != nil {
+		t.Fatalf("NOOP: %v", err)
+	}
+	if strings.Contains(res, "EXISTS") {
+		t.Errorf("NOOP %q, expected the buffered response to be discarded", res)
+	}
+	<-done
+
+	if err := c.StartTLS(); err == nil {
+		t.Errorf("StartTLS again succeeded, expected an error")
+	}
+}
@@ -0,0 +1,46 @@
+package imapclient
+
+import (
+	"bufio"
+	"compress/flate"
+	"fmt"
+	"io"
+	"net"
+)
+
+// Compress starts compression of the connection (COMPRESS DEFLATE, RFC 4978).
+// Call it after authentication, and after StartTLS if used.
+//
+//	if ok, _ := c.HasCapability("COMPRESS=DEFLATE"); ok {
+//		err = c.Compress()
+//	}
+func (c *Client) Compress() error {
+	if c.deflate != nil {
+		return fmt.Errorf("compression is already started")
+	}
+
+	_, err := c.Command("COMPRESS DEFLATE")
+	if err != nil {
+		return err
+	}
+
+	w, err := flate.NewWriter(c.conn, flate.DefaultCompression)
+	if err != nil {
+		return fmt.Errorf("failed to start compression: %v", err)
+	}
+	c.deflate = w
+
+	// the server may already have sent compressed data following the response, which is in c.r.
+	// flate does not read beyond the stream from a bufio.Reader.
+	//
+	// A read error is permanent to flate, so the inflated stream is passed through a pipe,
+	// whose read deadlines interrupt only the reader of the pipe (see Idle).
+	inflated, pw := net.Pipe()
+	go func(r io.Reader) {
+		io.Copy(pw, flate.NewReader(r))
+		pw.Close()
+	}(c.r)
+	c.rconn = inflated
+	c.r = bufio.NewReader(inflated)
+	return nil
+}
@@ -0,0 +1,127 @@
+package imapclient
+
+import (
+	"bufio"
+	"compress/flate"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// deflateConn compresses the writes to Conn, flushing every write.
+type deflateConn struct {
+	net.Conn
+	w *flate.Writer
+}
+
+func (c *deflateConn) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	if err == nil {
+		err = c.w.Flush()
+	}
+	return n, err
+}
+
+func TestCompress(t *testing.T) {
+	c, done := newFakeServer(t, func(s *fakeServer) {
+		s.expect("A1 COMPRESS DEFLATE")
+		s.send("A1 OK DEFLATE active")
+
+		fw, _ := flate.NewWriter(s.conn, flate.DefaultCompression)
+		s.conn = &deflateConn{Conn: s.conn, w: fw}
+		s.r = bufio.NewReader(flate.NewReader(s.r))
+
+		s.expect("A2 NOOP")
+		s.send("* 3 EXISTS", "A2 OK NOOP completed")
+
+		s.expect(`A3 SETMETADATA "INBOX" ("/private/comment" {12}`)
+		s.send("+ Ready")
+		if value := s.read(12); value != "hello\r\nworld" {
+			t.Errorf("server: got %q, expected %q", value, "hello\r\nworld")
+		}
+		s.expect(")")
+		s.send("A3 OK SETMETADATA completed")
+
+		s.expect("A4 FETCH 1 (BODY[])")
+		s.send("* 1 FETCH (BODY[] {7}", "a\r\nb\r\nc)", "A4 OK FETCH completed")
+
+		s.expect("A5 IDLE")
+		s.send("+ idling", "* 4 EXISTS")
+		s.expect("DONE")
+		s.send("A5 OK IDLE terminated")
+	})
+	c.mailbox = &MailboxStatus{Name: "INBOX"}
+
+	if err := c.Compress(); err != nil {
+		t.Fatalf("Compress: %v", err)
+	}
+	if err := c.Compress(); err == nil {
+		t.Errorf("Compress again succeeded, expected an error")
+	}
+
+	if err := c.Noop(); err != nil {
+		t.Fatalf("Noop: %v", err)
+	}
+	if err := c.SetMetadata("INBOX", map[string]string{"/private/comment": "hello\r\nworld"}); err != nil {
+		t.Fatalf("SetMetadata: %v", err)
+	}
+	res, err := c.Command("FETCH 1 (BODY[])")
+	if err != nil {
+		t.Fatalf("Command: %v", err)
+	}
+	if !strings.Contains(res, "{7}\r\na\r\nb\r\nc)") {
+		t.Errorf("FETCH %q, expected the literal", res)
+	}
+	if err := c.IdleWait(); err != nil {
+		t.Fatalf("IdleWait: %v", err)
+	}
+	if err := c.Done(); err != nil {
+		t.Fatalf("Done: %v", err)
+	}
+	<-done
+}
+
+func TestCompressIdleCancel(t *testing.T) {
+	c, done := newFakeServer(t, func(s *fakeServer) {
+		s.expect("A1 COMPRESS DEFLATE")
+		s.send("A1 OK DEFLATE active")
+
+		fw, _ := flate.NewWriter(s.conn, flate.DefaultCompression)
+		s.conn = &deflateConn{Conn: s.conn, w: fw}
+		s.r = bufio.NewReader(flate.NewReader(s.r))
+
+		s.expect("A2 IDLE")
+		s.send("+ idling", "* 3 EXISTS")
+		s.expect("DONE")
+		s.send("A2 OK IDLE terminated")
+		s.expect("A3 NOOP")
+		s.send("A3 OK NOOP completed")
+	})
+	c.caps = []string{"IMAP4rev1", "IDLE"}
+	c.mailbox = &MailboxStatus{Name: "INBOX"}
+
+	if err := c.Compress(); err != nil {
+		t.Fatalf("Compress: %v", err)
+	}
+
+	// the read deadline on cancel must not break the inflated stream
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, result := runIdle(ctx, c)
+	select {
+	case <-events:
+	case <-time.After(time.Second):
+		t.Fatalf("no event")
+	}
+	cancel()
+	if err := <-result; err != nil {
+		t.Fatalf("Idle: %v", err)
+	}
+
+	if err := c.Noop(); err != nil {
+		t.Errorf("Noop after Idle: %v", err)
+	}
+	<-done
+}
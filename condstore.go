@@ -0,0 +1,122 @@
+package imapclient
+
+import (
+	"fmt"
+	"strings"
+)
+
+// QResyncParams are what a client kept from the last session, for QRESYNC (RFC 7162).
+type QResyncParams struct {
+	UIDValidity uint32
+	ModSeq      uint64 // HIGHESTMODSEQ of the last session
+	// KnownUIDs optionally limits the changes to these UIDs.
+	KnownUIDs string
+}
+
+func (p QResyncParams) String() string {
+	s := fmt.Sprintf("(%d %d", p.UIDValidity, p.ModSeq)
+	if p.KnownUIDs != "" {
+		s += " " + p.KnownUIDs
+	}
+	return s + ")"
+}
+
+// Changes are changes of messages since a mod-sequence.
+type Changes struct {
+	// Messages are the messages whose flags were changed, with their UIDs and mod-sequences.
+	Messages []MessageFlags
+	// Vanished are the UIDs of the expunged messages (VANISHED).
+	Vanished SeqSet
+}
+
+// FetchChanges returns the changes of messages in uidSet since modSeq (UID FETCH CHANGEDSINCE).
+// The expunged messages are also returned if QRESYNC is enabled.
+//
+// With HighestModSeq of the last session, it catches up in one round trip:
+//
+//	changes, err := c.FetchChanges("1:*", lastModSeq)
+func (c *Client) FetchChanges(uidSet string, modSeq uint64) (*Changes, error) {
+	modifier := fmt.Sprintf("CHANGEDSINCE %d", modSeq)
+	if c.Enabled("QRESYNC") {
+		modifier += " VANISHED"
+	}
+
+	changes := &Changes{}
+	for _, set := range splitSeqSet(uidSet) {
+		res, err := c.Command(fmt.Sprintf("UID FETCH %s (UID FLAGS) (%s)", set, modifier))
+		if err != nil {
+			return nil, err
+		}
+
+		ch, err := parseChanges(res)
+		if err != nil {
+			return nil, err
+		}
+		changes.Messages = append(changes.Messages, ch.Messages...)
+		changes.Vanished.AddSet(ch.Vanished)
+
+		c.updateHighestModSeq(res, ch.Messages)
+	}
+	return changes, nil
+}
+
+// updateHighestModSeq raises HighestModSeq of the selected mailbox to the highest MODSEQ of msgs,
+// or to [HIGHESTMODSEQ] in res if the server sent it.
+func (c *Client) updateHighestModSeq(res string, msgs []MessageFlags) {
+	if c.mailbox == nil {
+		return
+	}
+
+	highest := c.mailbox.HighestModSeq
+	for _, msg := range msgs {
+		if msg.ModSeq > highest {
+			highest = msg.ModSeq
+		}
+	}
+	if arg, found := responseCode(res, "HIGHESTMODSEQ"); found {
+		if modseq, err := fieldUint64(arg); err == nil && modseq > highest {
+			highest = modseq
+		}
+	}
+	c.mailbox.HighestModSeq = highest
+}
+
+// parseChanges parses FETCH and VANISHED in res.
+func parseChanges(res string) (*Changes, error) {
+	msgs, err := parseFetchFlags(res)
+	if err != nil {
+		return nil, err
+	}
+	changes := &Changes{Messages: msgs}
+
+	for _, line := range untaggedLines(res, "VANISHED") {
+		vanished, _, err := parseVanished(line)
+		if err != nil {
+			return nil, err
+		}
+		changes.Vanished.AddSet(vanished)
+	}
+	return changes, nil
+}
+
+// parseVanished parses "VANISHED (EARLIER) uid-set".
+func parseVanished(line string) (uids SeqSet, earlier bool, err error) {
+	fields, err := parseFields(line[len("VANISHED"):])
+	if err != nil || len(fields) == 0 {
+		return SeqSet{}, false, fmt.Errorf("failed to parse %q: %v", line, err)
+	}
+
+	if tag := fieldList(fields[0]); tag != nil {
+		earlier = len(tag) == 1 && strings.EqualFold(fieldString(tag[0]), "EARLIER")
+		fields = fields[1:]
+	}
+	if len(fields) != 1 {
+		return SeqSet{}, false, fmt.Errorf("failed to parse %q", line)
+	}
+
+	uids, err = ParseSeqSet(fieldString(fields[0]))
+	if err != nil {
+		return SeqSet{}, false, err
+	}
+	return uids, earlier, nil
+}
@@ -0,0 +1,135 @@
+package imapclient
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseMailboxStatus(t *testing.T) {
+	res := "* FLAGS (\\Answered \\Flagged \\Deleted \\Seen \\Draft)\r\n" +
+		"* OK [PERMANENTFLAGS (\\Deleted \\Seen \\*)] Limited\r\n" +
+		"* 172 EXISTS\r\n" +
+		"* 1 RECENT\r\n" +
+		"* OK [UNSEEN 12] Message 12 is first unseen\r\n" +
+		"* OK [UIDVALIDITY 3857529045] UIDs valid\r\n" +
+		"* OK [UIDNEXT 4392] Predicted next UID\r\n" +
+		"* OK [HIGHESTMODSEQ 715194045007] Highest\r\n" +
+		"A142 OK [READ-WRITE] SELECT completed\r\n"
+
+	st, err := parseMailboxStatus("INBOX", res)
+	if err != nil {
+		t.Fatalf("parseMailboxStatus: %v", err)
+	}
+	if st.Exists != 172 || st.Recent != 1 || st.Unseen != 12 || st.UIDValidity != 3857529045 || st.UIDNext != 4392 ||
+		st.HighestModSeq != 715194045007 || st.ReadOnly || st.NoModSeq || len(st.Flags) != 5 || len(st.PermanentFlags) != 3 {
+		t.Errorf("unexpected status %#v", st)
+	}
+}
+
+func TestParseChanges(t *testing.T) {
+	res := "* VANISHED (EARLIER) 41,43:116,118,120:211,214:540\r\n" +
+		"* 49 FETCH (UID 117 FLAGS (\\Seen \\Answered) MODSEQ (90060115194045001))\r\n" +
+		"* 50 FETCH (UID 119 FLAGS (\\Draft $MDNSent) MODSEQ (90060115194045308))\r\n" +
+		"A02 OK [HIGHESTMODSEQ 90060115205545359] Sync completed\r\n"
+
+	ch, err := parseChanges(res)
+	if err != nil {
+		t.Fatalf("parseChanges: %v", err)
+	}
+	if len(ch.Messages) != 2 || ch.Messages[1].UID != 119 || ch.Messages[1].ModSeq != 90060115194045308 {
+		t.Errorf("unexpected messages %#v", ch.Messages)
+	}
+	if !ch.Vanished.Contains(43) || ch.Vanished.Contains(117) || ch.Vanished.String() != "41,43:116,118,120:211,214:540" {
+		t.Errorf("unexpected vanished %v", ch.Vanished)
+	}
+}
+
+func TestFetchChanges(t *testing.T) {
+	cases := []struct {
+		enabled map[string]bool
+		cmd     string
+		res     []string
+		expect  uint64 // HighestModSeq after
+	}{
+		{
+			nil, "A1 UID FETCH 1:* (UID FLAGS) (CHANGEDSINCE 100)",
+			[]string{`* 2 FETCH (UID 12 FLAGS (\Seen) MODSEQ (150))`, `* 5 FETCH (UID 20 FLAGS () MODSEQ (120))`},
+			150,
+		},
+		{
+			map[string]bool{"QRESYNC": true}, "A1 UID FETCH 1:* (UID FLAGS) (CHANGEDSINCE 100 VANISHED)",
+			[]string{"* VANISHED (EARLIER) 3:4", `* 2 FETCH (UID 12 FLAGS (\Seen) MODSEQ (130))`},
+			130,
+		},
+		{nil, "A1 UID FETCH 1:* (UID FLAGS) (CHANGEDSINCE 100)", nil, 110},
+		{
+			nil, "A1 UID FETCH 1:* (UID FLAGS) (CHANGEDSINCE 100)",
+			[]string{`* 2 FETCH (UID 12 FLAGS (\Seen) MODSEQ (130))`, "* OK [HIGHESTMODSEQ 200] Highest"},
+			200,
+		},
+	}
+
+	for i, cs := range cases {
+		c, done := newFakeServer(t, func(s *fakeServer) {
+			s.expect(cs.cmd)
+			s.send(cs.res...)
+			s.send("A1 OK FETCH completed")
+		})
+		c.enabled = cs.enabled
+		c.mailbox = &MailboxStatus{Name: "INBOX", HighestModSeq: 110}
+
+		if _, err := c.FetchChanges("1:*", 100); err != nil {
+			t.Errorf("%v: FetchChanges: %v", i, err)
+		}
+		<-done
+
+		if modseq := c.Mailbox().HighestModSeq; modseq != cs.expect {
+			t.Errorf("%v: HighestModSeq %v, expected %v", i, modseq, cs.expect)
+		}
+	}
+}
+
+func TestSelectWith(t *testing.T) {
+	cases := []struct {
+		opts   *SelectOptions
+		cmds   []string
+		expect uint64 // HighestModSeq
+	}{
+		{&SelectOptions{CondStore: true}, []string{`A1 SELECT "INBOX" (CONDSTORE)`}, 715194045007},
+		{&SelectOptions{ReadOnly: true, CondStore: true}, []string{`A1 EXAMINE "INBOX" (CONDSTORE)`}, 715194045007},
+		{
+			&SelectOptions{QResync: &QResyncParams{UIDValidity: 67890007, ModSeq: 90060115194045000, KnownUIDs: "41:211,214:541"}},
+			[]string{"A1 ENABLE QRESYNC", `A2 SELECT "INBOX" (QRESYNC (67890007 90060115194045000 41:211,214:541))`},
+			715194045007,
+		},
+	}
+
+	for i, cs := range cases {
+		c, done := newFakeServer(t, func(s *fakeServer) {
+			for n, cmd := range cs.cmds {
+				s.expect(cmd)
+				if n < len(cs.cmds)-1 {
+					s.send("* ENABLED QRESYNC", fmt.Sprintf("A%v OK ENABLE completed", n+1))
+					continue
+				}
+				s.send("* 172 EXISTS", "* OK [HIGHESTMODSEQ 715194045007] Highest",
+					"* VANISHED (EARLIER) 41,43:116", fmt.Sprintf("A%v OK [READ-WRITE] SELECT completed", n+1))
+			}
+		})
+
+		st, changes, err := c.SelectWith("INBOX", cs.opts)
+		<-done
+		if err != nil {
+			t.Errorf("%v: SelectWith: %v", i, err)
+			continue
+		}
+		if st.HighestModSeq != cs.expect {
+			t.Errorf("%v: HighestModSeq %v, expected %v", i, st.HighestModSeq, cs.expect)
+		}
+		if (changes != nil) != (cs.opts.QResync != nil) {
+			t.Errorf("%v: changes %+v, expected for QRESYNC only", i, changes)
+		} else if changes != nil && changes.Vanished.String() != "41,43:116" {
+			t.Errorf("%v: Vanished %v, expected %v", i, changes.Vanished.String(), "41,43:116")
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package imapclient
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeServer plays the server side of a Client connected by net.Pipe.
+type fakeServer struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// newFakeServer returns a Client connected to a fakeServer running script.
+// The returned channel is closed when script returns, and then the connection is closed.
+func newFakeServer(t *testing.T, script func(s *fakeServer)) (*Client, <-chan struct{}) {
+	cconn, sconn := net.Pipe()
+	c := &Client{conn: cconn, r: bufio.NewReader(cconn), name: "test"}
+
+	s := &fakeServer{t: t, conn: sconn, r: bufio.NewReader(sconn)}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer sconn.Close()
+		script(s)
+	}()
+	return c, done
+}
+
+// expect reads a line and reports an error unless it is line (CRLF trimmed).
+func (s *fakeServer) expect(line string) {
+	got, err := s.r.ReadString('\n')
+	if err != nil {
+		s.t.Errorf("server: failed to read %q: %v", line, err)
+		return
+	}
+	if got = strings.TrimSuffix(got, "\r\n"); got != line {
+		s.t.Errorf("server: got %q, expected %q", got, line)
+	}
+}
+
+// read reads n octets, such as a literal.
+func (s *fakeServer) read(n int) string {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(s.r, b); err != nil {
+		s.t.Errorf("server: failed to read %v octets: %v", n, err)
+	}
+	return string(b)
+}
+
+// send writes lines, each followed by CRLF.
+func (s *fakeServer) send(lines ...string) {
+	for _, line := range lines {
+		if _, err := io.WriteString(s.conn, line+"\r\n"); err != nil {
+			s.t.Errorf("server: failed to write %q: %v", line, err)
+			return
+		}
+	}
+}
+
+// testTLSConfig returns a server config with a self-signed certificate.
+// It calls t.Fatalf, so call it in the test goroutine, not in a script.
+func testTLSConfig(t *testing.T) *tls.Config {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "imap.example.com"},
+		DNSNames:     []string{"imap.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate: %v", err)
+	}
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+}
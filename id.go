@@ -0,0 +1,84 @@
+package imapclient
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Fields of ID (RFC 2971).
+const (
+	IDName        = "name"
+	IDVersion     = "version"
+	IDOS          = "os"
+	IDOSVersion   = "os-version"
+	IDVendor      = "vendor"
+	IDSupportURL  = "support-url"
+	IDAddress     = "address"
+	IDDate        = "date"
+	IDCommand     = "command"
+	IDArguments   = "arguments"
+	IDEnvironment = "environment"
+)
+
+// ID sends the identification of the client, such as {"name": "myclient", "version": "1.0"},
+// and returns that of the server (ID).
+// A nil or empty id sends NIL. The server may return nil.
+func (c *Client) ID(id map[string]string) (map[string]string, error) {
+	arg := "NIL"
+	if len(id) > 0 {
+		keys := make([]string, 0, len(id))
+		for k := range id {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		pairs := make([]string, 0, len(id))
+		for _, k := range keys {
+			pairs = append(pairs, quoteString(k)+" "+quoteString(id[k]))
+		}
+		arg = "(" + strings.Join(pairs, " ") + ")"
+	}
+
+	res, err := c.Command("ID " + arg)
+	if err != nil {
+		return nil, err
+	}
+
+	serverID, err := parseID(res)
+	if err != nil {
+		return nil, err
+	}
+	c.serverID = serverID
+	return serverID, nil
+}
+
+// ServerID returns the identification of the server returned by the last ID, or nil.
+func (c *Client) ServerID() map[string]string {
+	return c.serverID
+}
+
+// parseID parses `ID ("name" "Cyrus" "version" "1.5")` in res.
+func parseID(res string) (map[string]string, error) {
+	lines := untaggedLines(res, "ID")
+	if len(lines) == 0 {
+		return nil, nil
+	}
+
+	fields, err := parseFields(lines[0][len("ID"):])
+	if err != nil || len(fields) != 1 {
+		return nil, fmt.Errorf("failed to parse %q: %v", lines[0], err)
+	}
+
+	list := fieldList(fields[0])
+	if list == nil {
+		return nil, nil
+	}
+	id := make(map[string]string)
+	for i := 0; i+1 < len(list); i += 2 {
+		if list[i+1] != nil {
+			id[fieldString(list[i])] = fieldString(list[i+1])
+		}
+	}
+	return id, nil
+}
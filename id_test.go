@@ -0,0 +1,89 @@
+package imapclient
+
+import (
+	"bufio"
+	"crypto/tls"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseID(t *testing.T) {
+	res := "* ID (\"name\" \"Cyrus\" \"version\" \"1.5\" \"os\" NIL)\r\nA1 OK ID completed\r\n"
+	id, err := parseID(res)
+	if err != nil {
+		t.Fatalf("parseID: %v", err)
+	}
+	if want := map[string]string{IDName: "Cyrus", IDVersion: "1.5"}; !reflect.DeepEqual(id, want) {
+		t.Errorf("got %v, want %v", id, want)
+	}
+
+	id, err = parseID("* ID NIL\r\nA1 OK ID completed\r\n")
+	if err != nil || id != nil {
+		t.Errorf("got %v, %v, want nil", id, err)
+	}
+}
+
+func TestNewClientOptionsID(t *testing.T) {
+	cases := []struct {
+		caps  string
+		reply string // the response to ID, or "" if not sent
+	}{
+		{"IMAP4rev1 ID", `* ID ("name" "Dovecot")`},
+		{"IMAP4rev1 ID", "A2 BAD ID not allowed"},
+		{"IMAP4rev1", ""},
+	}
+
+	for i, cs := range cases {
+		ln, err := tls.Listen("tcp", "127.0.0.1:0", testTLSConfig(t))
+		if err != nil {
+			t.Fatalf("Listen: %v", err)
+		}
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			conn, err := ln.Accept()
+			if err != nil {
+				t.Errorf("%v: Accept: %v", i, err)
+				return
+			}
+			defer conn.Close()
+			s := &fakeServer{t: t, conn: conn, r: bufio.NewReader(conn)}
+
+			s.send("* OK IMAP4rev1 ready")
+			s.expect("A1 CAPABILITY")
+			s.send("* CAPABILITY "+cs.caps, "A1 OK CAPABILITY completed")
+			tag := "A2"
+			if cs.reply != "" {
+				s.expect(`A2 ID ("name" "test")`)
+				if strings.HasPrefix(cs.reply, "*") {
+					s.send(cs.reply, "A2 OK ID completed")
+				} else {
+					s.send(cs.reply)
+				}
+				tag = "A3"
+			}
+			s.expect(tag + " NOOP")
+			s.send(tag + " OK NOOP completed")
+		}()
+
+		c, err := NewClientOptions("tcp", ln.Addr().String(), &Options{
+			ID:        map[string]string{"name": "test"},
+			TLSConfig: &tls.Config{InsecureSkipVerify: true},
+		})
+		if err != nil {
+			t.Fatalf("%v: NewClientOptions: %v", i, err)
+		}
+		if err := c.Noop(); err != nil {
+			t.Errorf("%v: Noop: %v", i, err)
+		}
+		<-done
+		c.conn.Close()
+		ln.Close()
+
+		if expect := strings.HasPrefix(cs.reply, "*"); (c.ServerID() != nil) != expect {
+			t.Errorf("%v: ServerID() %v, expected %v", i, c.ServerID(), expect)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package imapclient
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// EventType is the type of an Event.
+type EventType int
+
+const (
+	// EventExists reports the number of messages in the mailbox (EXISTS).
+	EventExists EventType = iota + 1
+	// EventExpunge reports an expunged message (EXPUNGE).
+	EventExpunge
+	// EventFetch reports changed flags of a message (FETCH).
+	EventFetch
+	// EventMailboxName reports a mailbox created, deleted or renamed (NOTIFY).
+	EventMailboxName
+	// EventSubscriptionChange reports a mailbox subscribed or unsubscribed (NOTIFY).
+	EventSubscriptionChange
+	// EventVanished reports expunged messages by UIDs, instead of EventExpunge if QRESYNC is enabled.
+	EventVanished
+
+	// Events of messages in a mailbox other than the selected one, notified by NOTIFY with Mailbox and Status.
+	// In the selected mailbox, they are EventExists, EventExpunge and EventFetch.
+
+	// EventMessageNew reports new messages (MessageNew).
+	EventMessageNew
+	// EventMessageExpunge reports expunged messages (MessageExpunge).
+	EventMessageExpunge
+	// EventFlagChange reports changed flags (FlagChange).
+	EventFlagChange
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventExists:
+		return "EXISTS"
+	case EventExpunge:
+		return "EXPUNGE"
+	case EventFetch:
+		return "FETCH"
+	case EventMailboxName:
+		return "MailboxName"
+	case EventSubscriptionChange:
+		return "SubscriptionChange"
+	case EventVanished:
+		return "VANISHED"
+	case EventMessageNew:
+		return "MessageNew"
+	case EventMessageExpunge:
+		return "MessageExpunge"
+	case EventFlagChange:
+		return "FlagChange"
+	}
+	return fmt.Sprintf("EventType(%d)", int(t))
+}
+
+// Event is a change of a mailbox reported by the server.
+type Event struct {
+	Type EventType
+	// Num is the number of messages for EventExists, or the sequence number for EventExpunge and EventFetch.
+	// For a mailbox other than the selected one, it is the number of messages.
+	Num uint32
+	// Message is the flags of the message for EventFetch in the selected mailbox.
+	Message *MessageFlags
+	// UIDs are the expunged messages for EventVanished.
+	UIDs SeqSet
+
+	// Mailbox is the name of the mailbox for the events of NOTIFY, empty for the selected mailbox.
+	Mailbox string
+	// Status is STATUS of Mailbox.
+	Status map[string]uint32
+	// Item is LIST of Mailbox for EventMailboxName and EventSubscriptionChange.
+	Item *ListItem
+	// OldName is the name before renamed for EventMailboxName.
+	OldName string
+	// Subscribed reports whether Mailbox is subscribed for EventSubscriptionChange.
+	Subscribed bool
+}
+
+var (
+	// idleRefresh is the interval of re-issuing IDLE.
+	// Servers may log out a client idling for 30 minutes (RFC 2177).
+	idleRefresh = 25 * time.Minute
+	// idlePollInterval is the interval of NOOP if the server lacks IDLE.
+	idlePollInterval = time.Minute
+	// idleDoneTimeout is the wait for the response to DONE.
+	idleDoneTimeout = 30 * time.Second
+)
+
+// Idle waits for changes of the selected mailbox and sends them to events until ctx is done.
+// IDLE is re-issued before the server times out.
+// If the server lacks IDLE, NOOP is sent periodically instead.
+//
+// Idle returns nil when ctx is done, and the client can be used again.
+// If the server does not respond to DONE in 30 seconds, Idle returns an error and the client is broken.
+func (c *Client) Idle(ctx context.Context, events chan<- Event) error {
+	canIdle, err := c.HasCapability("IDLE")
+	if err != nil {
+		return err
+	}
+	if !canIdle {
+		return c.pollNoop(ctx, events)
+	}
+
+	for ctx.Err() == nil {
+		if err := c.idleOnce(ctx, events); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// idleOnce runs IDLE until ctx is done or idleRefresh passes.
+//
+// Only the calling goroutine reads and writes the connection.
+// ctx.Done and idleRefresh interrupt the read by the read deadline,
+// and then DONE is sent and the responses are read up to the tagged one.
+func (c *Client) idleOnce(ctx context.Context, events chan<- Event) error {
+	tag := c.makeNewTag()
+	res, err := c.Raw(tag, fmt.Sprintf("%v IDLE\r\n", tag))
+	if err != nil {
+		return err
+	}
+	c.sendEvents(ctx, events, res)
+
+	if err := c.setReadDeadline(time.Now().Add(idleRefresh)); err != nil {
+		return err
+	}
+	defer c.setReadDeadline(time.Time{})
+
+	// interrupt the read on ctx.Done
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			c.setReadDeadline(time.Now())
+		case <-stop:
+		}
+	}()
+	stopWatch := func() {
+		if stop != nil {
+			close(stop)
+			<-stopped
+			stop = nil
+		}
+	}
+	defer stopWatch()
+
+	done := false
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			if isTimeout(err) && !done {
+				stopWatch()
+				done = true
+				if err := c.setReadDeadline(time.Now().Add(idleDoneTimeout)); err != nil {
+					return err
+				}
+				if err := c.write("DONE\r\n"); err != nil {
+					return err
+				}
+				continue
+			}
+			if c.connErr == nil {
+				// no response to DONE; the connection is unusable
+				c.connErr = err
+			}
+			return fmt.Errorf("failed to read IDLE response: %v", err)
+		}
+
+		if strings.HasPrefix(line, tag+" ") {
+			st := strings.SplitN(line, " ", 3)[1]
+			if st != "OK" {
+				return fmt.Errorf("%v", line)
+			}
+			return nil
+		}
+
+		if ev, ok := c.parseEvent(line); ok {
+			sendEvent(ctx, events, ev)
+		}
+	}
+}
+
+func (c *Client) pollNoop(ctx context.Context, events chan<- Event) error {
+	ticker := time.NewTicker(idlePollInterval)
+	defer ticker.Stop()
+
+	for {
+		res, err := c.Command("NOOP")
+		if err != nil {
+			return err
+		}
+		c.sendEvents(ctx, events, res)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *Client) sendEvents(ctx context.Context, events chan<- Event, res string) {
+	for _, line := range splitResponse(res) {
+		if ev, ok := c.parseEvent(line); ok {
+			sendEvent(ctx, events, ev)
+		}
+	}
+}
+
+// sendEvent sends ev, or drops it if events is full and ctx is done.
+func sendEvent(ctx context.Context, events chan<- Event, ev Event) {
+	select {
+	case events <- ev:
+		return
+	default:
+	}
+
+	select {
+	case events <- ev:
+	case <-ctx.Done():
+	}
+}
+
+// parseEvent parses "* 3 EXISTS", "* 3 EXPUNGE", "* 3 FETCH (...)", "* VANISHED 3:5", and STATUS and LIST of NOTIFY.
+func (c *Client) parseEvent(line string) (Event, bool) {
+	if !strings.HasPrefix(line, "* ") {
+		return Event{}, false
+	}
+	comps := strings.SplitN(line[2:], " ", 3)
+	if len(comps) < 2 {
+		return Event{}, false
+	}
+	if strings.EqualFold(comps[0], "VANISHED") {
+		uids, earlier, err := parseVanished(line[2:])
+		if err != nil || earlier {
+			return Event{}, false
+		}
+		return Event{Type: EventVanished, UIDs: uids}, true
+	}
+
+	num, err := fieldUint32(comps[0])
+	if err != nil {
+		return c.parseNotifyEvent(line)
+	}
+
+	switch strings.ToUpper(comps[1]) {
+	case "EXISTS":
+		return Event{Type: EventExists, Num: num}, true
+
+	case "EXPUNGE":
+		return Event{Type: EventExpunge, Num: num}, true
+
+	case "FETCH":
+		msgs, err := parseFetchFlags(line)
+		if err != nil || len(msgs) != 1 {
+			return Event{}, false
+		}
+		return Event{Type: EventFetch, Num: num, Message: &msgs[0]}, true
+	}
+
+	return Event{}, false
+}
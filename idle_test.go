@@ -0,0 +1,285 @@
+package imapclient
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestParseEvent(t *testing.T) {
+	c := &Client{}
+
+	ev, ok := c.parseEvent("* 23 EXISTS")
+	if !ok || ev.Type != EventExists || ev.Num != 23 {
+		t.Errorf("EXISTS: %v %v", ev, ok)
+	}
+
+	ev, ok = c.parseEvent("* 3 EXPUNGE")
+	if !ok || ev.Type != EventExpunge || ev.Num != 3 {
+		t.Errorf("EXPUNGE: %v %v", ev, ok)
+	}
+
+	ev, ok = c.parseEvent(`* 5 FETCH (FLAGS (\Seen \Flagged) UID 42)`)
+	if !ok || ev.Type != EventFetch || ev.Num != 5 || ev.Message == nil || ev.Message.UID != 42 || len(ev.Message.Flags) != 2 {
+		t.Errorf("FETCH: %v %v", ev, ok)
+	}
+
+	if _, ok := c.parseEvent("* OK Still here"); ok {
+		t.Errorf("OK must not be an event")
+	}
+}
+
+func TestParseNotifyEvent(t *testing.T) {
+	c := &Client{notifySubscription: true}
+
+	ev, ok := c.parseEvent(`* STATUS "&ZeVnLIqe-" (MESSAGES 3 UIDNEXT 10)`)
+	if !ok || ev.Type != EventMessageNew || ev.Mailbox != "日本語" || ev.Num != 3 {
+		t.Errorf("STATUS: %v %v", ev, ok)
+	}
+	ev, ok = c.parseEvent(`* STATUS "&ZeVnLIqe-" (MESSAGES 2 UIDNEXT 10)`)
+	if !ok || ev.Type != EventMessageExpunge {
+		t.Errorf("STATUS: %v %v", ev, ok)
+	}
+
+	ev, ok = c.parseEvent(`* LIST () "/" "Archive/2017" ("OLDNAME" ("Archive/old"))`)
+	if !ok || ev.Type != EventMailboxName || ev.Mailbox != "Archive/2017" || ev.OldName != "Archive/old" {
+		t.Errorf("LIST: %v %v", ev, ok)
+	}
+	ev, ok = c.parseEvent(`* LIST (\Subscribed) "/" INBOX`)
+	if !ok || ev.Type != EventSubscriptionChange || !ev.Subscribed || ev.Item.Delim != "/" {
+		t.Errorf("LIST: %v %v", ev, ok)
+	}
+}
+
+func TestParseNotifyEventMailboxName(t *testing.T) {
+	// both MailboxName and SubscriptionChange are notified
+	c := &Client{notifySubscription: true, notifyMailboxName: true, notifyMailboxes: map[string]bool{"INBOX": false}}
+
+	cases := []struct {
+		line       string
+		expect     EventType
+		subscribed bool
+	}{
+		{`* LIST (\Subscribed) "/" INBOX`, EventSubscriptionChange, true},
+		{`* LIST () "/" INBOX`, EventSubscriptionChange, false},
+		{`* LIST () "/" Drafts`, EventMailboxName, false},
+		{`* LIST (\Subscribed) "/" Drafts`, EventSubscriptionChange, true},
+		{`* LIST (\NonExistent) "/" Drafts`, EventMailboxName, false},
+		{`* LIST (\Subscribed) "/" Drafts`, EventMailboxName, false},
+	}
+
+	for i, cs := range cases {
+		ev, ok := c.parseEvent(cs.line)
+		if !ok || ev.Type != cs.expect || ev.Subscribed != cs.subscribed {
+			t.Errorf("%v: %v %v, expected %v (subscribed %v)", i, ev, ok, cs.expect, cs.subscribed)
+		}
+	}
+}
+
+func TestEventTypeString(t *testing.T) {
+	cases := []struct {
+		typ    EventType
+		expect string
+	}{
+		{EventExists, "EXISTS"},
+		{EventMessageNew, "MessageNew"},
+		{EventMessageExpunge, "MessageExpunge"},
+		{EventFlagChange, "FlagChange"},
+		{EventType(100), "EventType(100)"},
+	}
+
+	for _, cs := range cases {
+		if s := cs.typ.String(); s != cs.expect {
+			t.Errorf("String() %q, expected %q", s, cs.expect)
+		}
+	}
+}
+
+func TestNotify(t *testing.T) {
+	c, done := newFakeServer(t, func(s *fakeServer) {
+		s.expect(`A1 LIST "" "*"`)
+		s.send(`* LIST () "/" INBOX`, `* LIST () "/" Sent`, "A1 OK LIST completed")
+		s.expect(`A2 LSUB "" "*"`)
+		s.send(`* LSUB () "/" Sent`, "A2 OK LSUB completed")
+		s.expect(`A3 NOTIFY SET STATUS (PERSONAL (MessageNew MailboxName SubscriptionChange))`)
+		s.send(`* STATUS INBOX (MESSAGES 3 UIDNEXT 10)`, "A3 OK NOTIFY completed")
+	})
+
+	err := c.Notify(true, NotifySet{Filter: NotifyPersonal, Events: []NotifyEvent{NotifyMessageNew, NotifyMailboxName, NotifySubscriptionChange}})
+	if err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	<-done
+
+	if c.notifyMailboxes["INBOX"] || !c.notifyMailboxes["Sent"] || c.notifyStatus["INBOX"]["MESSAGES"] != 3 {
+		t.Errorf("unexpected state %v %v", c.notifyMailboxes, c.notifyStatus)
+	}
+	if ev, _ := c.parseEvent(`* LIST (\Subscribed) "/" INBOX`); ev.Type != EventSubscriptionChange || !ev.Subscribed {
+		t.Errorf("unexpected event %v", ev)
+	}
+	if ev, _ := c.parseEvent(`* LIST () "/" Trash`); ev.Type != EventMailboxName {
+		t.Errorf("unexpected event %v", ev)
+	}
+}
+
+// runIdle runs Idle of c in a goroutine, returning its events and the result.
+func runIdle(ctx context.Context, c *Client) (<-chan Event, <-chan error) {
+	events := make(chan Event, 10)
+	result := make(chan error, 1)
+	go func() {
+		result <- c.Idle(ctx, events)
+	}()
+	return events, result
+}
+
+func TestIdle(t *testing.T) {
+	defer func(d time.Duration) { idleRefresh = d }(idleRefresh)
+	idleRefresh = 50 * time.Millisecond
+
+	c, done := newFakeServer(t, func(s *fakeServer) {
+		s.expect("A1 IDLE")
+		s.send("+ idling", "* 3 EXISTS")
+		// DONE after idleRefresh, and IDLE again
+		s.expect("DONE")
+		s.send("* 1 EXPUNGE", "A1 OK IDLE terminated")
+		s.expect("A2 IDLE")
+		s.send("+ idling", "* 4 EXISTS")
+		// DONE on cancel
+		s.expect("DONE")
+		s.send("A2 OK IDLE terminated")
+		s.expect("A3 NOOP")
+		s.send("A3 OK NOOP completed")
+	})
+	c.caps = []string{"IMAP4rev1", "IDLE"}
+	c.mailbox = &MailboxStatus{Name: "INBOX"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, result := runIdle(ctx, c)
+
+	cases := []struct {
+		typ EventType
+		num uint32
+	}{
+		{EventExists, 3},
+		{EventExpunge, 1},
+		{EventExists, 4},
+	}
+	for i, cs := range cases {
+		select {
+		case ev := <-events:
+			if ev.Type != cs.typ || ev.Num != cs.num {
+				t.Errorf("%v: event %v %v, expected %v %v", i, ev.Type, ev.Num, cs.typ, cs.num)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%v: no event, expected %v %v", i, cs.typ, cs.num)
+		}
+	}
+	cancel()
+
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Errorf("Idle: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Idle did not return on cancel")
+	}
+
+	// the client is usable after Idle
+	if err := c.Noop(); err != nil {
+		t.Errorf("Noop after Idle: %v", err)
+	}
+	<-done
+}
+
+func TestIdleDeadConnection(t *testing.T) {
+	defer func(d time.Duration) { idleDoneTimeout = d }(idleDoneTimeout)
+	idleDoneTimeout = 50 * time.Millisecond
+
+	release := make(chan struct{})
+	c, done := newFakeServer(t, func(s *fakeServer) {
+		s.expect("A1 IDLE")
+		s.send("+ idling")
+		// no response to DONE
+		s.expect("DONE")
+		<-release
+	})
+	c.caps = []string{"IMAP4rev1", "IDLE"}
+	c.mailbox = &MailboxStatus{Name: "INBOX"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, result := runIdle(ctx, c)
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-result:
+		if err == nil {
+			t.Errorf("Idle succeeded, expected an error")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Idle did not return on cancel")
+	}
+	if c.connErr == nil {
+		t.Errorf("connErr is nil after no response to DONE")
+	}
+	close(release)
+	<-done
+}
+
+func TestIdlePollNoop(t *testing.T) {
+	defer func(d time.Duration) { idlePollInterval = d }(idlePollInterval)
+	idlePollInterval = 20 * time.Millisecond
+
+	c, done := newFakeServer(t, func(s *fakeServer) {
+		s.expect("A1 NOOP")
+		s.send("* 4 EXISTS", "A1 OK NOOP completed")
+		s.expect("A2 NOOP")
+		s.send("* 2 EXPUNGE", "A2 OK NOOP completed")
+		// until cancelled
+		for n := 3; ; n++ {
+			if _, err := s.r.ReadString('\n'); err != nil {
+				return
+			}
+			s.send(fmt.Sprintf("A%v OK NOOP completed", n))
+		}
+	})
+	c.caps = []string{"IMAP4rev1"}
+	c.mailbox = &MailboxStatus{Name: "INBOX"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, result := runIdle(ctx, c)
+
+	cases := []struct {
+		typ EventType
+		num uint32
+	}{
+		{EventExists, 4},
+		{EventExpunge, 2},
+	}
+	for i, cs := range cases {
+		select {
+		case ev := <-events:
+			if ev.Type != cs.typ || ev.Num != cs.num {
+				t.Errorf("%v: event %v %v, expected %v %v", i, ev.Type, ev.Num, cs.typ, cs.num)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%v: no event, expected %v %v", i, cs.typ, cs.num)
+		}
+	}
+	cancel()
+
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Errorf("Idle: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Idle did not return on cancel")
+	}
+	c.conn.Close()
+	<-done
+}
@@ -0,0 +1,221 @@
+package imapclient
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ListOptions are the options of LIST-EXTENDED (RFC 5258).
+type ListOptions struct {
+	// Selection options
+
+	// Subscribed lists subscribed mailboxes, including non-existent ones (SUBSCRIBED).
+	Subscribed bool
+	// Remote lists remote mailboxes too (REMOTE).
+	Remote bool
+	// RecursiveMatch lists parents of matched mailboxes with ChildInfo (RECURSIVEMATCH).
+	// It needs Subscribed.
+	RecursiveMatch bool
+	// SpecialUse lists special-use mailboxes only (SPECIAL-USE, RFC 6154).
+	SpecialUse bool
+
+	// Return options
+
+	// ReturnChildren returns \HasChildren or \HasNoChildren (CHILDREN).
+	ReturnChildren bool
+	// ReturnSubscribed returns \Subscribed (SUBSCRIBED).
+	ReturnSubscribed bool
+	// ReturnSpecialUse returns special-use attributes such as \Sent (SPECIAL-USE, RFC 6154).
+	ReturnSpecialUse bool
+	// ReturnStatus returns STATUS of these items, such as "MESSAGES" and "UNSEEN", in ListItem.Status (LIST-STATUS, RFC 5819).
+	// If the server lacks LIST-STATUS, STATUS commands are pipelined instead.
+	ReturnStatus []string
+}
+
+// statusBatch is the number of STATUS commands pipelined at once.
+const statusBatch = 50
+
+// ListExtended lists mailboxes matching any of patterns with LIST-EXTENDED.
+// It fails if the server lacks LIST-EXTENDED for the options or patterns,
+// or SPECIAL-USE for the special-use options.
+// ReturnStatus alone does not need LIST-EXTENDED.
+func (c *Client) ListExtended(opts *ListOptions, reference string, patterns ...string) ([]ListItem, error) {
+	if opts == nil {
+		opts = &ListOptions{}
+	}
+	if len(patterns) == 0 {
+		patterns = []string{"*"}
+	}
+
+	if opts.extended() || len(patterns) > 1 {
+		hasExtended, err := c.HasCapability("LIST-EXTENDED")
+		if err != nil {
+			return nil, err
+		}
+		if !hasExtended {
+			return nil, fmt.Errorf("the server does not support LIST-EXTENDED")
+		}
+	}
+	if opts.SpecialUse || opts.ReturnSpecialUse {
+		hasSpecialUse, err := c.HasCapability("SPECIAL-USE")
+		if err != nil {
+			return nil, err
+		}
+		if !hasSpecialUse {
+			return nil, fmt.Errorf("the server does not support SPECIAL-USE")
+		}
+	}
+
+	listStatus := false
+	if len(opts.ReturnStatus) > 0 {
+		var err error
+		listStatus, err = c.HasCapability("LIST-STATUS")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cmd, err := c.listCommand(opts, reference, patterns, listStatus)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.Command(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]ListItem, 0, 10)
+	for _, line := range untaggedLines(res, "LIST") {
+		item, _, err := c.parseListLine(line)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	if len(opts.ReturnStatus) > 0 {
+		if !listStatus {
+			res, err = c.pipelineStatus(items, opts.ReturnStatus)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if err := c.fillStatus(items, res); err != nil {
+			return nil, err
+		}
+	}
+
+	return items, nil
+}
+
+// extended reports whether opts have the options of LIST-EXTENDED, except ReturnStatus.
+func (opts *ListOptions) extended() bool {
+	return opts.Subscribed || opts.Remote || opts.RecursiveMatch || opts.SpecialUse ||
+		opts.ReturnChildren || opts.ReturnSubscribed || opts.ReturnSpecialUse
+}
+
+// pipelineStatus sends STATUS of selectable items at once, and returns all the responses.
+func (c *Client) pipelineStatus(items []ListItem, statusItems []string) (string, error) {
+	cmds := make([]string, 0, len(items))
+	for _, item := range items {
+		if item.hasAttr("\\Noselect") || item.hasAttr("\\NonExistent") {
+			continue
+		}
+		mailbox, err := c.quoteMailbox(item.Name)
+		if err != nil {
+			return "", fmt.Errorf("failed to encode mailbox: %v", err)
+		}
+		cmds = append(cmds, fmt.Sprintf("STATUS %v (%v)", mailbox, strings.Join(statusItems, " ")))
+	}
+
+	var all string
+	for len(cmds) > 0 {
+		n := statusBatch
+		if n > len(cmds) {
+			n = len(cmds)
+		}
+
+		// a mailbox failed (NO) just lacks its status
+		responses, _, err := c.pipeline(cmds[:n])
+		if err != nil {
+			return "", err
+		}
+		all += strings.Join(responses, "")
+		cmds = cmds[n:]
+	}
+	return all, nil
+}
+
+// fillStatus sets Status of items by STATUS responses in res.
+func (c *Client) fillStatus(items []ListItem, res string) error {
+	for _, line := range untaggedLines(res, "STATUS") {
+		mailbox, st, err := c.parseStatusLine(line)
+		if err != nil {
+			return err
+		}
+		for i := range items {
+			if items[i].Name == mailbox {
+				items[i].Status = st
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Client) listCommand(opts *ListOptions, reference string, patterns []string, listStatus bool) (string, error) {
+	sel := make([]string, 0, 3)
+	if opts.Subscribed {
+		sel = append(sel, "SUBSCRIBED")
+	}
+	if opts.Remote {
+		sel = append(sel, "REMOTE")
+	}
+	if opts.RecursiveMatch {
+		sel = append(sel, "RECURSIVEMATCH")
+	}
+	if opts.SpecialUse {
+		sel = append(sel, "SPECIAL-USE")
+	}
+
+	ret := make([]string, 0, 2)
+	if opts.ReturnChildren {
+		ret = append(ret, "CHILDREN")
+	}
+	if opts.ReturnSubscribed {
+		ret = append(ret, "SUBSCRIBED")
+	}
+	if opts.ReturnSpecialUse {
+		ret = append(ret, "SPECIAL-USE")
+	}
+	if listStatus {
+		ret = append(ret, "STATUS ("+strings.Join(opts.ReturnStatus, " ")+")")
+	}
+
+	reference, err := c.quoteMailbox(reference)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode reference: %v", err)
+	}
+	quoted := make([]string, 0, len(patterns))
+	for _, p := range patterns {
+		q, err := c.quoteMailbox(p)
+		if err != nil {
+			return "", fmt.Errorf("failed to encode mailbox: %v", err)
+		}
+		quoted = append(quoted, q)
+	}
+
+	cmd := "LIST "
+	if len(sel) > 0 {
+		cmd += "(" + strings.Join(sel, " ") + ") "
+	}
+	cmd += reference + " "
+	if len(quoted) == 1 {
+		cmd += quoted[0]
+	} else {
+		cmd += "(" + strings.Join(quoted, " ") + ")"
+	}
+	if len(ret) > 0 {
+		cmd += " RETURN (" + strings.Join(ret, " ") + ")"
+	}
+	return cmd, nil
+}
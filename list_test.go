@@ -0,0 +1,201 @@
+package imapclient
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestListCommand(t *testing.T) {
+	cases := []struct {
+		opts     *ListOptions
+		patterns []string
+		expect   string
+	}{
+		{&ListOptions{}, []string{"*"}, `LIST "" "*"`},
+		{&ListOptions{Subscribed: true, RecursiveMatch: true}, []string{"*"}, `LIST (SUBSCRIBED RECURSIVEMATCH) "" "*"`},
+		{&ListOptions{Remote: true, SpecialUse: true}, []string{"INBOX", "日本語/*"}, `LIST (REMOTE SPECIAL-USE) "" ("INBOX" "&ZeVnLIqe-/*")`},
+		{&ListOptions{ReturnChildren: true, ReturnSubscribed: true, ReturnSpecialUse: true}, []string{"%"}, `LIST "" "%" RETURN (CHILDREN SUBSCRIBED SPECIAL-USE)`},
+		{&ListOptions{Subscribed: true, ReturnChildren: true}, []string{"a", "b"}, `LIST (SUBSCRIBED) "" ("a" "b") RETURN (CHILDREN)`},
+	}
+
+	c := &Client{}
+	for i, cs := range cases {
+		cmd, err := c.listCommand(cs.opts, "", cs.patterns, false)
+		if err != nil {
+			t.Errorf("%v: listCommand: %v", i, err)
+		} else if cmd != cs.expect {
+			t.Errorf("%v: listCommand %q, expected %q", i, cmd, cs.expect)
+		}
+	}
+}
+
+func TestParseListLine(t *testing.T) {
+	cases := []struct {
+		line   string
+		expect ListItem
+	}{
+		{`LIST (\HasNoChildren) "/" INBOX`, ListItem{Attrs: []string{`\HasNoChildren`}, Delim: "/", Name: "INBOX"}},
+		{`LIST () NIL "&ZeVnLIqe-"`, ListItem{Attrs: []string{}, Name: "日本語"}},
+		{
+			`LIST (\NonExistent) "/" Foo ("CHILDINFO" ("SUBSCRIBED"))`,
+			ListItem{Attrs: []string{`\NonExistent`}, Delim: "/", Name: "Foo", ChildInfo: []string{"SUBSCRIBED"}},
+		},
+		{`LSUB (\Noselect) "." "#news.comp"`, ListItem{Attrs: []string{`\Noselect`}, Delim: ".", Name: "#news.comp"}},
+	}
+
+	c := &Client{}
+	for i, cs := range cases {
+		item, _, err := c.parseListLine(cs.line)
+		if err != nil {
+			t.Errorf("%v: parseListLine: %v", i, err)
+		} else if !reflect.DeepEqual(item, cs.expect) {
+			t.Errorf("%v: parseListLine %#v, expected %#v", i, item, cs.expect)
+		}
+	}
+}
+
+func TestLSub(t *testing.T) {
+	c, done := newFakeServer(t, func(s *fakeServer) {
+		s.expect(`A1 LSUB "" "*"`)
+		s.send(`* LSUB () "/" INBOX`, `* LSUB (\Noselect) "/" "Work"`, `* LSUB () "/" "Work/&ZeVnLIqe-"`, "A1 OK LSUB completed")
+	})
+
+	items, err := c.LSub("", "*")
+	if err != nil {
+		t.Fatalf("LSub: %v", err)
+	}
+	<-done
+
+	if len(items) != 3 || !items[1].Attributes().Has(AttrNoselect) || items[2].Name != "Work/日本語" {
+		t.Errorf("unexpected items %#v", items)
+	}
+}
+
+func TestListCommandStatus(t *testing.T) {
+	cases := []struct {
+		opts       *ListOptions
+		listStatus bool
+		expect     string
+	}{
+		{&ListOptions{ReturnStatus: []string{"MESSAGES", "UNSEEN"}}, true, `LIST "" "*" RETURN (STATUS (MESSAGES UNSEEN))`},
+		{&ListOptions{ReturnChildren: true, ReturnStatus: []string{"UIDNEXT"}}, true, `LIST "" "*" RETURN (CHILDREN STATUS (UIDNEXT))`},
+		// without LIST-STATUS, STATUS is sent separately
+		{&ListOptions{ReturnStatus: []string{"MESSAGES"}}, false, `LIST "" "*"`},
+	}
+
+	c := &Client{}
+	for i, cs := range cases {
+		cmd, err := c.listCommand(cs.opts, "", []string{"*"}, cs.listStatus)
+		if err != nil {
+			t.Errorf("%v: listCommand: %v", i, err)
+		} else if cmd != cs.expect {
+			t.Errorf("%v: listCommand %q, expected %q", i, cmd, cs.expect)
+		}
+	}
+}
+
+func TestFillStatus(t *testing.T) {
+	res := "* LIST () \"/\" INBOX\r\n" +
+		"* STATUS INBOX (MESSAGES 17 UNSEEN 16)\r\n" +
+		"* LIST () \"/\" \"&ZeVnLIqe-\"\r\n" +
+		"* LIST (\\Noselect) \"/\" Work\r\n" +
+		"* STATUS \"&ZeVnLIqe-\" (MESSAGES 3 UNSEEN 0)\r\n" +
+		"A1 OK LIST completed\r\n"
+	items := []ListItem{{Name: "INBOX"}, {Name: "日本語"}, {Name: "Work"}}
+
+	c := &Client{}
+	if err := c.fillStatus(items, res); err != nil {
+		t.Fatalf("fillStatus: %v", err)
+	}
+
+	cases := []struct {
+		item   ListItem
+		expect map[string]uint32
+	}{
+		{items[0], map[string]uint32{"MESSAGES": 17, "UNSEEN": 16}},
+		{items[1], map[string]uint32{"MESSAGES": 3, "UNSEEN": 0}},
+		{items[2], nil},
+	}
+	for i, cs := range cases {
+		if !reflect.DeepEqual(cs.item.Status, cs.expect) {
+			t.Errorf("%v: Status of %v %v, expected %v", i, cs.item.Name, cs.item.Status, cs.expect)
+		}
+	}
+}
+
+func TestListExtendedPipelineStatus(t *testing.T) {
+	c, done := newFakeServer(t, func(s *fakeServer) {
+		s.expect(`A1 LIST "" "*"`)
+		s.send(`* LIST () "/" INBOX`, `* LIST () "/" Gone`, `* LIST (\Noselect) "/" Work`, `* LIST () "/" Work/Sent`, "A1 OK LIST completed")
+
+		// pipelined: all the commands come before the responses
+		s.expect(`A2 STATUS "INBOX" (MESSAGES)`)
+		s.expect(`A3 STATUS "Gone" (MESSAGES)`)
+		s.expect(`A4 STATUS "Work/Sent" (MESSAGES)`)
+		s.send("* STATUS INBOX (MESSAGES 5)", "A2 OK STATUS completed")
+		s.send("A3 NO Mailbox doesn't exist")
+		s.send(`* STATUS "Work/Sent" (MESSAGES 2)`, "A4 OK STATUS completed")
+	})
+	c.caps = []string{"IMAP4rev1"}
+
+	items, err := c.ListExtended(&ListOptions{ReturnStatus: []string{"MESSAGES"}}, "")
+	if err != nil {
+		t.Fatalf("ListExtended: %v", err)
+	}
+	<-done
+
+	cases := []struct {
+		name   string
+		expect map[string]uint32
+	}{
+		{"INBOX", map[string]uint32{"MESSAGES": 5}},
+		{"Gone", nil},
+		{"Work", nil},
+		{"Work/Sent", map[string]uint32{"MESSAGES": 2}},
+	}
+	if len(items) != len(cases) {
+		t.Fatalf("ListExtended %v items, expected %v", len(items), len(cases))
+	}
+	for i, cs := range cases {
+		if items[i].Name != cs.name || !reflect.DeepEqual(items[i].Status, cs.expect) {
+			t.Errorf("%v: %v %v, expected %v %v", i, items[i].Name, items[i].Status, cs.name, cs.expect)
+		}
+	}
+}
+
+func TestListExtendedCapability(t *testing.T) {
+	cases := []struct {
+		opts     *ListOptions
+		patterns []string
+		caps     string
+		expect   string // the command, or "" if refused
+	}{
+		{&ListOptions{Subscribed: true}, []string{"*"}, "IMAP4rev1 LIST-EXTENDED", `A1 LIST (SUBSCRIBED) "" "*"`},
+		{&ListOptions{Subscribed: true}, []string{"*"}, "IMAP4rev1", ""},
+		{&ListOptions{}, []string{"INBOX", "Sent"}, "IMAP4rev1", ""},
+		{&ListOptions{ReturnChildren: true}, []string{"*"}, "IMAP4rev1 LIST-EXTENDED", `A1 LIST "" "*" RETURN (CHILDREN)`},
+		{&ListOptions{ReturnSpecialUse: true}, []string{"*"}, "IMAP4rev1 LIST-EXTENDED", ""},
+		{&ListOptions{SpecialUse: true}, []string{"*"}, "IMAP4rev1 LIST-EXTENDED SPECIAL-USE", `A1 LIST (SPECIAL-USE) "" "*"`},
+		{&ListOptions{ReturnStatus: []string{"MESSAGES"}}, []string{"*"}, "IMAP4rev1 LIST-STATUS", `A1 LIST "" "*" RETURN (STATUS (MESSAGES))`},
+	}
+
+	for i, cs := range cases {
+		c, done := newFakeServer(t, func(s *fakeServer) {
+			if cs.expect == "" {
+				return
+			}
+			s.expect(cs.expect)
+			s.send(`* LIST () "/" INBOX`, "A1 OK LIST completed")
+		})
+		c.caps = strings.Fields(cs.caps)
+
+		_, err := c.ListExtended(cs.opts, "", cs.patterns...)
+		if cs.expect == "" && err == nil {
+			t.Errorf("%v: ListExtended succeeded with %q, expected an error", i, cs.caps)
+		} else if cs.expect != "" && err != nil {
+			t.Errorf("%v: ListExtended: %v", i, err)
+		}
+		<-done
+	}
+}
@@ -0,0 +1,166 @@
+package imapclient
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Depths of MetadataOptions.
+const (
+	MetadataDepth0        = "0"        // the entries only
+	MetadataDepth1        = "1"        // the entries and their children
+	MetadataDepthInfinity = "infinity" // the entries and all their descendants
+)
+
+// MetadataOptions are the options of GetMetadata.
+type MetadataOptions struct {
+	// MaxSize omits the values larger than MaxSize octets, if not 0.
+	MaxSize uint32
+	// Depth is one of MetadataDepth0, MetadataDepth1 and MetadataDepthInfinity, or "" for the default (0).
+	Depth string
+}
+
+// GetMetadata returns the values of entries, such as "/private/comment", of mailbox (GETMETADATA, RFC 5464).
+// mailbox "" means the server annotations.
+// The entries that do not exist are not in values. At least one entry is needed.
+//
+// If values are omitted by MaxSize, longEntries is the size of the largest one.
+func (c *Client) GetMetadata(mailbox string, opts *MetadataOptions, entries ...string) (values map[string]string, longEntries uint32, err error) {
+	if len(entries) == 0 {
+		return nil, 0, fmt.Errorf("no entries")
+	}
+
+	quoted, err := c.quoteMailbox(mailbox)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to encode mailbox: %v", err)
+	}
+
+	cmd := "GETMETADATA "
+	if opts != nil {
+		options := make([]string, 0, 2)
+		if opts.MaxSize != 0 {
+			options = append(options, fmt.Sprintf("MAXSIZE %d", opts.MaxSize))
+		}
+		if opts.Depth != "" {
+			options = append(options, "DEPTH "+opts.Depth)
+		}
+		if len(options) > 0 {
+			cmd += "(" + strings.Join(options, " ") + ") "
+		}
+	}
+	quotedEntries := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		quotedEntries = append(quotedEntries, quoteString(entry))
+	}
+	cmd += quoted + " (" + strings.Join(quotedEntries, " ") + ")"
+
+	res, err := c.Command(cmd)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	values, err = c.parseMetadata(res, mailbox)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if arg, found := responseCode(res, "METADATA"); found {
+		comps := strings.Fields(arg)
+		if len(comps) == 2 && strings.EqualFold(comps[0], "LONGENTRIES") {
+			longEntries, _ = fieldUint32(comps[1])
+		}
+	}
+	return values, longEntries, nil
+}
+
+// SetMetadata sets the values of entries of mailbox (SETMETADATA, RFC 5464).
+// mailbox "" means the server annotations.
+// The values are sent as literals in the order of the entries, or literal8 if they contain NUL, which needs BINARY (RFC 3516).
+// At least one value is needed.
+func (c *Client) SetMetadata(mailbox string, values map[string]string) error {
+	if len(values) == 0 {
+		return fmt.Errorf("no entries")
+	}
+
+	quoted, err := c.quoteMailbox(mailbox)
+	if err != nil {
+		return fmt.Errorf("failed to encode mailbox: %v", err)
+	}
+
+	entries := make([]string, 0, len(values))
+	for entry := range values {
+		entries = append(entries, entry)
+	}
+	sort.Strings(entries)
+
+	for _, value := range values {
+		if strings.IndexByte(value, 0) == -1 {
+			continue
+		}
+		hasBinary, err := c.HasCapability("BINARY")
+		if err != nil {
+			return err
+		}
+		if !hasBinary {
+			return fmt.Errorf("the server does not support BINARY")
+		}
+		break
+	}
+
+	lc := newLiteralCommand("SETMETADATA " + quoted + " (")
+	for i, entry := range entries {
+		value := values[entry]
+		if i > 0 {
+			lc.text(" ")
+		}
+		lc.text(quoteString(entry) + " ")
+		if strings.IndexByte(value, 0) != -1 {
+			lc.text("~")
+		}
+		lc.literal(value)
+	}
+	lc.text(")")
+
+	_, err = c.commandLiterals(lc)
+	return err
+}
+
+// DeleteMetadata removes entries of mailbox (SETMETADATA with NIL).
+func (c *Client) DeleteMetadata(mailbox string, entries ...string) error {
+	quoted, err := c.quoteMailbox(mailbox)
+	if err != nil {
+		return fmt.Errorf("failed to encode mailbox: %v", err)
+	}
+
+	pairs := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		pairs = append(pairs, quoteString(entry)+" NIL")
+	}
+	_, err = c.Command("SETMETADATA " + quoted + " (" + strings.Join(pairs, " ") + ")")
+	return err
+}
+
+// parseMetadata parses `METADATA "INBOX" (/private/comment "My comment")` of mailbox in res.
+func (c *Client) parseMetadata(res, mailbox string) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, line := range untaggedLines(res, "METADATA") {
+		fields, err := parseFields(line[len("METADATA"):])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %q: %v", line, err)
+		}
+		// an unsolicited METADATA has entries without values
+		if len(fields) != 2 || fieldList(fields[1]) == nil || c.decodeMailbox(fieldString(fields[0])) != mailbox {
+			continue
+		}
+
+		list := fieldList(fields[1])
+		for i := 0; i+1 < len(list); i += 2 {
+			if list[i+1] == nil {
+				continue
+			}
+			values[fieldString(list[i])] = fieldString(list[i+1])
+		}
+	}
+	return values, nil
+}
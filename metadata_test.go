@@ -0,0 +1,93 @@
+package imapclient
+
+import (
+	"testing"
+)
+
+func TestParseMetadata(t *testing.T) {
+	c := &Client{}
+	res := "* METADATA \"INBOX\" (/private/comment \"My comment\" /shared/color {3}\r\nred /shared/x NIL)\r\n" +
+		"* METADATA \"Sent\" (/private/comment \"other\")\r\n" +
+		"* METADATA \"INBOX\" /shared/changed\r\n" +
+		"A1 OK [METADATA LONGENTRIES 2199] done\r\n"
+
+	values, err := c.parseMetadata(res, "INBOX")
+	if err != nil {
+		t.Fatalf("parseMetadata: %v", err)
+	}
+	if len(values) != 2 || values["/private/comment"] != "My comment" || values["/shared/color"] != "red" {
+		t.Errorf("unexpected values %#v", values)
+	}
+}
+
+func TestSetMetadataBinary(t *testing.T) {
+	cases := []struct {
+		caps   []string
+		value  string
+		expect string // the command line, or "" if refused
+	}{
+		{[]string{"IMAP4rev1", "METADATA"}, "text", `A1 SETMETADATA "INBOX" ("/private/x" {4}`},
+		{[]string{"IMAP4rev1", "METADATA", "BINARY"}, "a\x00b", `A1 SETMETADATA "INBOX" ("/private/x" ~{3}`},
+		{[]string{"IMAP4rev1", "METADATA"}, "a\x00b", ""},
+	}
+
+	for i, cs := range cases {
+		c, done := newFakeServer(t, func(s *fakeServer) {
+			if cs.expect == "" {
+				return
+			}
+			s.expect(cs.expect)
+			s.send("+ Ready")
+			if value := s.read(len(cs.value)); value != cs.value {
+				t.Errorf("%v: server: got %q, expected %q", i, value, cs.value)
+			}
+			s.expect(")")
+			s.send("A1 OK SETMETADATA completed")
+		})
+		c.caps = cs.caps
+
+		err := c.SetMetadata("INBOX", map[string]string{"/private/x": cs.value})
+		if cs.expect == "" && err == nil {
+			t.Errorf("%v: SetMetadata succeeded, expected an error", i)
+		} else if cs.expect != "" && err != nil {
+			t.Errorf("%v: SetMetadata: %v", i, err)
+		}
+		c.conn.Close()
+		<-done
+	}
+}
+
+func TestSetMetadataOrder(t *testing.T) {
+	c, done := newFakeServer(t, func(s *fakeServer) {
+		s.expect(`A1 SETMETADATA "INBOX" ("/private/a" {1}`)
+		s.send("+ Ready")
+		s.read(1)
+		s.expect(` "/private/b" {1}`)
+		s.send("+ Ready")
+		s.read(1)
+		s.expect(` "/shared/a" {1}`)
+		s.send("+ Ready")
+		s.read(1)
+		s.expect(")")
+		s.send("A1 OK SETMETADATA completed")
+	})
+	c.caps = []string{"IMAP4rev1", "METADATA"}
+
+	err := c.SetMetadata("INBOX", map[string]string{"/shared/a": "3", "/private/b": "2", "/private/a": "1"})
+	if err != nil {
+		t.Errorf("SetMetadata: %v", err)
+	}
+	<-done
+}
+
+func TestMetadataNoEntries(t *testing.T) {
+	c, done := newFakeServer(t, func(s *fakeServer) {})
+
+	if _, _, err := c.GetMetadata("INBOX", nil); err == nil {
+		t.Errorf("GetMetadata succeeded, expected an error")
+	}
+	if err := c.SetMetadata("INBOX", map[string]string{}); err == nil {
+		t.Errorf("SetMetadata succeeded, expected an error")
+	}
+	<-done
+}
@@ -0,0 +1,183 @@
+package imapclient
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NamespaceKind is the kind of a namespace (RFC 2342).
+type NamespaceKind int
+
+const (
+	NamespacePersonal NamespaceKind = iota
+	NamespaceOther                  // other users' namespace
+	NamespaceShared
+)
+
+func (k NamespaceKind) String() string {
+	switch k {
+	case NamespacePersonal:
+		return "Personal"
+	case NamespaceOther:
+		return "Other"
+	case NamespaceShared:
+		return "Shared"
+	}
+	return fmt.Sprintf("NamespaceKind(%d)", int(k))
+}
+
+// Namespace is a namespace described by its prefix and delimiter, such as "#shared/" and "/".
+type Namespace struct {
+	Prefix string
+	Delim  string // "" for a flat namespace
+	// Extensions are the extension data, such as "TRANSLATION" -> ["Shared"].
+	Extensions map[string][]string
+}
+
+// Namespaces are the namespaces of the server.
+type Namespaces struct {
+	Personal []Namespace
+	Other    []Namespace
+	Shared   []Namespace
+}
+
+// Namespace returns the namespaces of the server (NAMESPACE).
+// If the server lacks NAMESPACE, a personal namespace with an empty prefix and the delimiter of LIST is returned.
+func (c *Client) Namespace() (*Namespaces, error) {
+	hasNamespace, err := c.HasCapability("NAMESPACE")
+	if err != nil {
+		return nil, err
+	}
+	if !hasNamespace {
+		items, err := c.List("", "")
+		if err != nil {
+			return nil, err
+		}
+		ns := Namespace{}
+		if len(items) > 0 {
+			ns.Delim = items[0].Delim
+		}
+		return &Namespaces{Personal: []Namespace{ns}}, nil
+	}
+
+	res, err := c.Command("NAMESPACE")
+	if err != nil {
+		return nil, err
+	}
+
+	lines := untaggedLines(res, "NAMESPACE")
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("no NAMESPACE response")
+	}
+	return c.parseNamespaceLine(lines[0])
+}
+
+// parseNamespaceLine parses `NAMESPACE (("" "/")) NIL (("#shared/" "/"))`.
+func (c *Client) parseNamespaceLine(line string) (*Namespaces, error) {
+	fields, err := parseFields(line[len("NAMESPACE"):])
+	if err != nil || len(fields) != 3 {
+		return nil, fmt.Errorf("failed to parse %q: %v", line, err)
+	}
+
+	nss := &Namespaces{}
+	for i, dst := range []*[]Namespace{&nss.Personal, &nss.Other, &nss.Shared} {
+		for _, f := range fieldList(fields[i]) {
+			descr := fieldList(f)
+			if len(descr) < 2 {
+				return nil, fmt.Errorf("failed to parse %q", line)
+			}
+
+			ns := Namespace{
+				Prefix: c.decodeMailbox(fieldString(descr[0])),
+				Delim:  fieldString(descr[1]),
+			}
+			for j := 2; j+1 < len(descr); j += 2 {
+				if ns.Extensions == nil {
+					ns.Extensions = make(map[string][]string)
+				}
+				ns.Extensions[fieldString(descr[j])] = fieldStrings(descr[j+1])
+			}
+			*dst = append(*dst, ns)
+		}
+	}
+	return nss, nil
+}
+
+// Lookup returns the namespace which name belongs to, by the longest prefix.
+// It returns nil if name belongs to no namespace.
+func (nss *Namespaces) Lookup(name string) (NamespaceKind, *Namespace) {
+	var kind NamespaceKind
+	var found *Namespace
+	for k, list := range [][]Namespace{nss.Personal, nss.Other, nss.Shared} {
+		for i := range list {
+			ns := &list[i]
+			if !ns.contains(name) {
+				continue
+			}
+			if found == nil || len(ns.Prefix) > len(found.Prefix) {
+				kind, found = NamespaceKind(k), ns
+			}
+		}
+	}
+	return kind, found
+}
+
+// Of returns the namespaces of kind.
+func (nss *Namespaces) Of(kind NamespaceKind) []Namespace {
+	switch kind {
+	case NamespacePersonal:
+		return nss.Personal
+	case NamespaceOther:
+		return nss.Other
+	case NamespaceShared:
+		return nss.Shared
+	}
+	return nil
+}
+
+// Filter returns the items in the namespaces of kind.
+func (nss *Namespaces) Filter(items []ListItem, kind NamespaceKind) []ListItem {
+	filtered := make([]ListItem, 0, len(items))
+	for _, item := range items {
+		if k, ns := nss.Lookup(item.Name); ns != nil && k == kind {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered
+}
+
+// contains reports whether name is in ns.
+// The prefix without the trailing delimiter (such as "#shared" for "#shared/") is also in ns.
+func (ns Namespace) contains(name string) bool {
+	if strings.HasPrefix(name, ns.Prefix) {
+		return true
+	}
+	return ns.Delim != "" && name+ns.Delim == ns.Prefix
+}
+
+// ListNamespace lists the mailboxes matching pattern in the namespaces of kind.
+// pattern is relative to the prefix of each namespace, such as "*".
+//
+// To build the tree of the personal mailboxes:
+//
+//	nss, err := c.Namespace()
+//	items, err := c.ListNamespace(nss, NamespacePersonal, "*")
+//	tree := NewMailboxTree(items)
+func (c *Client) ListNamespace(nss *Namespaces, kind NamespaceKind, pattern string) ([]ListItem, error) {
+	items := make([]ListItem, 0, 10)
+	listed := make(map[string]bool)
+	for _, ns := range nss.Of(kind) {
+		nsItems, err := c.List("", ns.Prefix+pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range nsItems {
+			if !listed[item.Name] {
+				listed[item.Name] = true
+				items = append(items, item)
+			}
+		}
+	}
+	// a personal namespace with an empty prefix matches the others too
+	return nss.Filter(items, kind), nil
+}
@@ -0,0 +1,46 @@
+package imapclient
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseNamespace(t *testing.T) {
+	c := &Client{}
+	nss, err := c.parseNamespaceLine(`NAMESPACE (("" "/")) NIL (("#shared/" "/" "X-PARAM" ("A" "B"))("#public." "."))`)
+	if err != nil {
+		t.Fatalf("parseNamespaceLine: %v", err)
+	}
+
+	expect := &Namespaces{
+		Personal: []Namespace{{Prefix: "", Delim: "/"}},
+		Shared: []Namespace{
+			{Prefix: "#shared/", Delim: "/", Extensions: map[string][]string{"X-PARAM": {"A", "B"}}},
+			{Prefix: "#public.", Delim: "."},
+		},
+	}
+	if !reflect.DeepEqual(nss, expect) {
+		t.Errorf("parseNamespaceLine %+v, expected %+v", nss, expect)
+	}
+
+	cases := []struct {
+		name   string
+		expect NamespaceKind
+	}{
+		{"INBOX", NamespacePersonal},
+		{"Work/2017", NamespacePersonal},
+		{"#shared/team", NamespaceShared},
+		{"#shared", NamespaceShared},
+		{"#public.news", NamespaceShared},
+	}
+	for i, cs := range cases {
+		if kind, ns := nss.Lookup(cs.name); ns == nil || kind != cs.expect {
+			t.Errorf("%v: Lookup(%q) %v %v, expected %v", i, cs.name, kind, ns, cs.expect)
+		}
+	}
+
+	items := []ListItem{{Name: "INBOX"}, {Name: "#shared/team"}, {Name: "Sent"}}
+	if filtered := nss.Filter(items, NamespacePersonal); len(filtered) != 2 || filtered[1].Name != "Sent" {
+		t.Errorf("Filter %v, expected INBOX and Sent", filtered)
+	}
+}
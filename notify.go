@@ -0,0 +1,227 @@
+package imapclient
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NotifyEvent is an event of NOTIFY (RFC 5465).
+type NotifyEvent string
+
+const (
+	NotifyMessageNew         NotifyEvent = "MessageNew"
+	NotifyMessageExpunge     NotifyEvent = "MessageExpunge"
+	NotifyFlagChange         NotifyEvent = "FlagChange"
+	NotifyMailboxName        NotifyEvent = "MailboxName"
+	NotifySubscriptionChange NotifyEvent = "SubscriptionChange"
+)
+
+// Mailbox filters of NotifySet.
+const (
+	NotifySelected        = "SELECTED"
+	NotifySelectedDelayed = "SELECTED-DELAYED"
+	NotifyInboxes         = "INBOXES"
+	NotifyPersonal        = "PERSONAL"
+	NotifySubscribed      = "SUBSCRIBED"
+	NotifySubtree         = "SUBTREE"   // with Mailboxes
+	NotifyMailboxes       = "MAILBOXES" // with Mailboxes
+)
+
+// NotifySet is a set of mailboxes and the events to be notified about them.
+//
+//	NotifySet{Filter: NotifyMailboxes, Mailboxes: []string{"INBOX", "Sent"}, Events: []NotifyEvent{NotifyMessageNew}}
+type NotifySet struct {
+	Filter    string
+	Mailboxes []string // for NotifySubtree and NotifyMailboxes
+	Events    []NotifyEvent
+}
+
+// Notify asks the server to notify events of sets (NOTIFY SET).
+// The events are sent to the channel of Idle, along with EXISTS, EXPUNGE and FETCH of the selected mailbox.
+//
+// If status is true, the server reports the current STATUS of the mailboxes first.
+func (c *Client) Notify(status bool, sets ...NotifySet) error {
+	cmd := "NOTIFY SET"
+	if status {
+		cmd += " STATUS"
+	}
+
+	subscription, mailboxName := false, false
+	for _, set := range sets {
+		cmd += " (" + set.Filter
+		if len(set.Mailboxes) > 0 {
+			mailboxes := make([]string, 0, len(set.Mailboxes))
+			for _, mailbox := range set.Mailboxes {
+				quoted, err := c.quoteMailbox(mailbox)
+				if err != nil {
+					return fmt.Errorf("failed to encode mailbox: %v", err)
+				}
+				mailboxes = append(mailboxes, quoted)
+			}
+			cmd += " (" + strings.Join(mailboxes, " ") + ")"
+		}
+
+		if len(set.Events) == 0 {
+			cmd += " NONE)"
+			continue
+		}
+		events := make([]string, 0, len(set.Events))
+		for _, ev := range set.Events {
+			events = append(events, string(ev))
+			switch ev {
+			case NotifySubscriptionChange:
+				subscription = true
+			case NotifyMailboxName:
+				mailboxName = true
+			}
+		}
+		cmd += " (" + strings.Join(events, " ") + "))"
+	}
+
+	// a LIST of a known mailbox is a subscription change, otherwise a creation
+	var known map[string]bool
+	if subscription && mailboxName {
+		var err error
+		known, err = c.subscribedMailboxes()
+		if err != nil {
+			return err
+		}
+	}
+
+	res, err := c.Command(cmd)
+	if err != nil {
+		return err
+	}
+
+	c.notifySubscription = subscription
+	c.notifyMailboxName = mailboxName
+	c.notifyMailboxes = known
+	c.notifyStatus = make(map[string]map[string]uint32)
+	for _, line := range untaggedLines(res, "STATUS") {
+		if mailbox, st, err := c.parseStatusLine(line); err == nil {
+			c.notifyStatus[mailbox] = st
+		}
+	}
+	return nil
+}
+
+// NotifyNone stops all notifications (NOTIFY NONE).
+func (c *Client) NotifyNone() error {
+	_, err := c.Command("NOTIFY NONE")
+	if err != nil {
+		return err
+	}
+	c.notifySubscription = false
+	c.notifyMailboxName = false
+	c.notifyStatus = nil
+	c.notifyMailboxes = nil
+	return nil
+}
+
+// subscribedMailboxes returns all the mailboxes and whether they are subscribed.
+func (c *Client) subscribedMailboxes() (map[string]bool, error) {
+	items, err := c.List("", "*")
+	if err != nil {
+		return nil, err
+	}
+	subscribed, err := c.LSub("", "*")
+	if err != nil {
+		return nil, err
+	}
+
+	mailboxes := make(map[string]bool)
+	for _, item := range items {
+		mailboxes[item.Name] = false
+	}
+	for _, item := range subscribed {
+		if _, found := mailboxes[item.Name]; found {
+			mailboxes[item.Name] = true
+		}
+	}
+	return mailboxes, nil
+}
+
+// parseNotifyEvent parses STATUS and LIST sent by NOTIFY.
+//
+// STATUS of a mailbox other than the selected one is reported as EventMessageNew
+// if MESSAGES or UIDNEXT grows (or nothing is known about the mailbox yet),
+// EventMessageExpunge if MESSAGES shrinks, or EventFlagChange otherwise.
+//
+// LIST is reported as EventMailboxName if the mailbox is renamed or deleted.
+// Otherwise it is EventSubscriptionChange if only SubscriptionChange is notified,
+// or EventMailboxName (created) if only MailboxName is notified.
+// If both are notified, it is EventSubscriptionChange for a mailbox known to exist, or EventMailboxName (created).
+func (c *Client) parseNotifyEvent(line string) (Event, bool) {
+	switch {
+	case strings.HasPrefix(line, "* STATUS "):
+		mailbox, st, err := c.parseStatusLine(line[2:])
+		if err != nil {
+			return Event{}, false
+		}
+
+		ev := Event{Type: EventMessageNew, Num: st["MESSAGES"], Mailbox: mailbox, Status: st}
+		if prev, found := c.notifyStatus[mailbox]; found {
+			messages, msgFound := st["MESSAGES"]
+			uidNext := st["UIDNEXT"]
+			switch {
+			case msgFound && messages < prev["MESSAGES"]:
+				ev.Type = EventMessageExpunge
+			case msgFound && messages > prev["MESSAGES"], uidNext > prev["UIDNEXT"]:
+				ev.Type = EventMessageNew
+			default:
+				ev.Type = EventFlagChange
+			}
+		}
+		if c.notifyStatus == nil {
+			c.notifyStatus = make(map[string]map[string]uint32)
+		}
+		c.notifyStatus[mailbox] = st
+		return ev, true
+
+	case strings.HasPrefix(line, "* LIST "):
+		item, extended, err := c.parseListLine(line[2:])
+		if err != nil {
+			return Event{}, false
+		}
+
+		ev := Event{Type: EventMailboxName, Mailbox: item.Name, Item: &item}
+		for i := 0; i+1 < len(extended); i += 2 {
+			if strings.EqualFold(fieldString(extended[i]), "OLDNAME") {
+				if old := fieldStrings(extended[i+1]); len(old) == 1 {
+					ev.OldName = c.decodeMailbox(old[0])
+				}
+			}
+		}
+
+		deleted := item.hasAttr("\\NonExistent")
+		if ev.OldName == "" && !deleted && c.notifySubscription {
+			if _, known := c.notifyMailboxes[item.Name]; known || !c.notifyMailboxName {
+				ev.Type = EventSubscriptionChange
+				ev.Subscribed = item.hasAttr("\\Subscribed")
+			}
+		}
+
+		if c.notifyMailboxes != nil {
+			if ev.OldName != "" {
+				delete(c.notifyMailboxes, ev.OldName)
+			}
+			if deleted {
+				delete(c.notifyMailboxes, item.Name)
+			} else {
+				c.notifyMailboxes[item.Name] = item.hasAttr("\\Subscribed")
+			}
+		}
+		return ev, true
+	}
+
+	return Event{}, false
+}
+
+func (li ListItem) hasAttr(attr string) bool {
+	for _, a := range li.Attrs {
+		if strings.EqualFold(a, attr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,261 @@
+package imapclient
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseFields splits a response line (or a part of it) into fields.
+//
+// A parenthesized list becomes []interface{}, NIL becomes nil,
+// and atoms, quoted strings and literals become string.
+func parseFields(s string) ([]interface{}, error) {
+	fields, pos, err := parseFieldList(s, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	if pos < len(s) {
+		return nil, fmt.Errorf("unexpected %q at %v", s[pos], pos)
+	}
+	return fields, nil
+}
+
+// parseFieldList parses fields from s[pos:] until closing (0 means the end of s).
+// It returns the position just after closing.
+func parseFieldList(s string, pos int, closing byte) ([]interface{}, int, error) {
+	fields := make([]interface{}, 0, 4)
+
+	for pos < len(s) {
+		switch s[pos] {
+		case ' ', '\r', '\n':
+			pos++
+
+		case ')':
+			if closing != ')' {
+				return nil, pos, fmt.Errorf("unexpected ) at %v", pos)
+			}
+			return fields, pos + 1, nil
+
+		case '(':
+			list, next, err := parseFieldList(s, pos+1, ')')
+			if err != nil {
+				return nil, next, err
+			}
+			fields = append(fields, list)
+			pos = next
+
+		case '"':
+			str, next, err := parseQuoted(s, pos)
+			if err != nil {
+				return nil, next, err
+			}
+			fields = append(fields, str)
+			pos = next
+
+		case '{', '~':
+			str, next, err := parseLiteral(s, pos)
+			if err != nil {
+				return nil, next, err
+			}
+			fields = append(fields, str)
+			pos = next
+
+		default:
+			atom, next := parseAtom(s, pos)
+			if strings.EqualFold(atom, "NIL") {
+				fields = append(fields, nil)
+			} else {
+				fields = append(fields, atom)
+			}
+			pos = next
+		}
+	}
+
+	if closing != 0 {
+		return nil, pos, fmt.Errorf("missing %q", closing)
+	}
+	return fields, pos, nil
+}
+
+func parseQuoted(s string, pos int) (string, int, error) {
+	var b strings.Builder
+	for i := pos + 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+			if i < len(s) {
+				b.WriteByte(s[i])
+			}
+		case '"':
+			return b.String(), i + 1, nil
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return "", len(s), fmt.Errorf("unterminated quoted string at %v", pos)
+}
+
+// parseLiteral parses {n}CRLF or ~{n}CRLF (literal8) followed by n octets.
+func parseLiteral(s string, pos int) (string, int, error) {
+	start := pos
+	if s[pos] == '~' {
+		pos++
+	}
+	if pos >= len(s) || s[pos] != '{' {
+		atom, next := parseAtom(s, start)
+		return atom, next, nil
+	}
+
+	posEnd := strings.IndexByte(s[pos:], '}')
+	if posEnd == -1 {
+		return "", len(s), fmt.Errorf("unterminated literal at %v", start)
+	}
+	posEnd += pos
+	n, err := strconv.Atoi(strings.TrimSuffix(s[pos+1:posEnd], "+"))
+	if err != nil {
+		return "", posEnd, fmt.Errorf("unexpected literal length %q", s[pos+1:posEnd])
+	}
+
+	pos = posEnd + 1
+	if strings.HasPrefix(s[pos:], "\r\n") {
+		pos += 2
+	}
+	if pos+n > len(s) {
+		return "", len(s), fmt.Errorf("literal too short (expected %v octets)", n)
+	}
+	return s[pos : pos+n], pos + n, nil
+}
+
+// parseAtom parses an atom. Brackets are kept in the atom, as in BODY[HEADER.FIELDS (FROM)].
+func parseAtom(s string, pos int) (string, int) {
+	depth := 0
+	i := pos
+	for ; i < len(s); i++ {
+		ch := s[i]
+		if ch == '[' {
+			depth++
+		} else if ch == ']' && depth > 0 {
+			depth--
+		} else if depth == 0 && (ch == ' ' || ch == '(' || ch == ')' || ch == '\r' || ch == '\n') {
+			break
+		}
+	}
+	return s[pos:i], i
+}
+
+// fieldString returns f as a string ("" for NIL or a list).
+func fieldString(f interface{}) string {
+	if s, ok := f.(string); ok {
+		return s
+	}
+	return ""
+}
+
+// fieldList returns f as a list (nil for NIL or a string).
+func fieldList(f interface{}) []interface{} {
+	if l, ok := f.([]interface{}); ok {
+		return l
+	}
+	return nil
+}
+
+func fieldUint32(f interface{}) (uint32, error) {
+	v, err := strconv.ParseUint(fieldString(f), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected number %v", f)
+	}
+	return uint32(v), nil
+}
+
+func fieldUint64(f interface{}) (uint64, error) {
+	v, err := strconv.ParseUint(fieldString(f), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected number %v", f)
+	}
+	return v, nil
+}
+
+// fieldStrings returns the strings in the list f.
+func fieldStrings(f interface{}) []string {
+	list := fieldList(f)
+	strs := make([]string, 0, len(list))
+	for _, v := range list {
+		strs = append(strs, fieldString(v))
+	}
+	return strs
+}
+
+// untaggedLines returns the untagged responses of res that begin with name, case insensitive.
+// Each returned line begins with the name ("* " is trimmed).
+func untaggedLines(res, name string) []string {
+	lines := make([]string, 0, 1)
+	for _, line := range splitResponse(res) {
+		if !strings.HasPrefix(line, "* ") {
+			continue
+		}
+		rest := line[2:]
+		if len(rest) > len(name) && strings.EqualFold(rest[:len(name)], name) && rest[len(name)] == ' ' {
+			lines = append(lines, rest)
+		} else if strings.EqualFold(rest, name) {
+			lines = append(lines, rest)
+		}
+	}
+	return lines
+}
+
+// splitResponse splits res into response lines.
+// CRLFs inside literals do not split the line.
+func splitResponse(res string) []string {
+	lines := make([]string, 0, 4)
+	start := 0
+	for pos := 0; pos < len(res); {
+		crlf := strings.Index(res[pos:], "\r\n")
+		if crlf == -1 {
+			break
+		}
+		crlf += pos
+
+		// {n} at the end of a line is a literal of n octets following CRLF
+		if crlf > 0 && res[crlf-1] == '}' {
+			if posSt := strings.LastIndexByte(res[pos:crlf], '{'); posSt != -1 {
+				n, err := strconv.Atoi(strings.TrimSuffix(res[pos+posSt+1:crlf-1], "+"))
+				if err == nil && crlf+2+n <= len(res) {
+					pos = crlf + 2 + n
+					continue
+				}
+			}
+		}
+
+		lines = append(lines, res[start:crlf])
+		pos = crlf + 2
+		start = pos
+	}
+	if start < len(res) {
+		lines = append(lines, res[start:])
+	}
+	return lines
+}
+
+// responseCode finds the response code [name ...] in res and returns its argument.
+func responseCode(res, name string) (arg string, found bool) {
+	for _, line := range splitResponse(res) {
+		posSt := strings.IndexByte(line, '[')
+		if posSt == -1 {
+			continue
+		}
+		posEd := strings.IndexByte(line[posSt:], ']')
+		if posEd == -1 {
+			continue
+		}
+		code := line[posSt+1 : posSt+posEd]
+
+		if strings.EqualFold(code, name) {
+			return "", true
+		}
+		if len(code) > len(name) && strings.EqualFold(code[:len(name)], name) && code[len(name)] == ' ' {
+			return code[len(name)+1:], true
+		}
+	}
+	return "", false
+}
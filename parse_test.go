@@ -0,0 +1,57 @@
+package imapclient
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFields(t *testing.T) {
+	cases := []struct {
+		s      string
+		expect []interface{}
+	}{
+		{`INBOX`, []interface{}{"INBOX"}},
+		{`"a \"b\" c" NIL atom`, []interface{}{`a "b" c`, nil, "atom"}},
+		{`(\Seen \Answered) ()`, []interface{}{[]interface{}{`\Seen`, `\Answered`}, []interface{}{}}},
+		{"BODY[HEADER.FIELDS (FROM)] {5}\r\nab\r\nc", []interface{}{"BODY[HEADER.FIELDS (FROM)]", "ab\r\nc"}},
+		{"(~{2}\r\n\x00\x01 x)", []interface{}{[]interface{}{"\x00\x01", "x"}}},
+	}
+	for i, cs := range cases {
+		fields, err := parseFields(cs.s)
+		if err != nil {
+			t.Errorf("%v: parseFields(%q): %v", i, cs.s, err)
+		} else if !reflect.DeepEqual(fields, cs.expect) {
+			t.Errorf("%v: parseFields(%q) %#v, expected %#v", i, cs.s, fields, cs.expect)
+		}
+	}
+
+	for i, s := range []string{`(a`, `a)`, `"a`, "{5}\r\nab"} {
+		if fields, err := parseFields(s); err == nil {
+			t.Errorf("%v: parseFields(%q) %#v, expected an error", i, s, fields)
+		}
+	}
+}
+
+func TestSplitResponse(t *testing.T) {
+	res := "* 1 FETCH (BODY[] {7}\r\na\r\nb\r\nc)\r\n* SEARCH 1 2\r\nA1 OK done\r\n"
+	lines := splitResponse(res)
+	expect := []string{"* 1 FETCH (BODY[] {7}\r\na\r\nb\r\nc)", "* SEARCH 1 2", "A1 OK done"}
+	if !reflect.DeepEqual(lines, expect) {
+		t.Errorf("splitResponse %q, expected %q", lines, expect)
+	}
+
+	cases := []struct {
+		name   string
+		expect []string
+	}{
+		{"SEARCH", []string{"SEARCH 1 2"}},
+		{"search", []string{"SEARCH 1 2"}},
+		{"SEARC", []string{}},
+		{"ESEARCH", []string{}},
+	}
+	for i, cs := range cases {
+		if lines := untaggedLines(res, cs.name); !reflect.DeepEqual(lines, cs.expect) {
+			t.Errorf("%v: untaggedLines(%q) %q, expected %q", i, cs.name, lines, cs.expect)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package imapclient
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Account is the key of Pool.
+type Account struct {
+	Network  string
+	Addr     string
+	Username string
+}
+
+// PoolOptions are the options of NewPool.
+type PoolOptions struct {
+	// Dial connects to the server of account and authenticates.
+	Dial func(account Account) (*Client, error)
+	// MaxPerServer limits the connections to an Addr, including idle ones, if not 0.
+	MaxPerServer int
+	// IdleTimeout closes the connections idle longer than it, if not 0.
+	// They are closed on Get and Put, and by a goroutine checking every half of IdleTimeout until Close.
+	IdleTimeout time.Duration
+}
+
+// Pool is a pool of authenticated clients keyed by Account.
+//
+//	c, err := pool.Get(ctx, account)
+//	if err != nil {
+//		return err
+//	}
+//	defer pool.Put(account, c)
+type Pool struct {
+	opts PoolOptions
+
+	mu     sync.Mutex
+	idle   map[Account][]idleClient
+	open   map[string]int // by Addr
+	wake   chan struct{}  // closed when a connection is returned or closed
+	stop   chan struct{}  // closed by Close to stop reap
+	closed bool
+}
+
+type idleClient struct {
+	c     *Client
+	since time.Time
+}
+
+// NewPool returns a pool with opts. opts.Dial is required.
+func NewPool(opts PoolOptions) *Pool {
+	p := &Pool{
+		opts: opts,
+		idle: make(map[Account][]idleClient),
+		open: make(map[string]int),
+		wake: make(chan struct{}),
+		stop: make(chan struct{}),
+	}
+	if opts.IdleTimeout > 0 {
+		interval := opts.IdleTimeout / 2
+		if interval == 0 {
+			interval = opts.IdleTimeout
+		}
+		go p.reap(interval)
+	}
+	return p
+}
+
+// Get returns a client of account.
+// An idle client is checked by NOOP, or a new one is dialed.
+// If MaxPerServer connections are open, Get waits for one to be returned until ctx is done.
+func (p *Pool) Get(ctx context.Context, account Account) (*Client, error) {
+	for {
+		c, dial, wake, err := p.checkout(account)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case c != nil:
+			if err := c.Noop(); err != nil {
+				p.Discard(account, c)
+				continue
+			}
+			return c, nil
+
+		case dial:
+			c, err := p.opts.Dial(account)
+			if err != nil {
+				p.release(account)
+				return nil, err
+			}
+			return c, nil
+		}
+
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// checkout takes an idle client of account, or reserves a connection to be dialed.
+// If neither is possible, it returns the channel to wait on.
+func (p *Pool) checkout(account Account) (c *Client, dial bool, wake chan struct{}, err error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, false, nil, fmt.Errorf("pool is closed")
+	}
+	expired := p.expiredLocked()
+
+	if idle := p.idle[account]; len(idle) > 0 {
+		c = idle[len(idle)-1].c
+		p.idle[account] = idle[:len(idle)-1]
+	} else if p.opts.MaxPerServer == 0 || p.open[account.Addr] < p.opts.MaxPerServer {
+		p.open[account.Addr]++
+		dial = true
+	} else if other := p.idleOfServerLocked(account.Addr); other != nil {
+		// an idle connection of another account gives way
+		expired = append(expired, other)
+		dial = true
+	} else {
+		wake = p.wake
+	}
+	p.mu.Unlock()
+
+	closeClients(expired)
+	return c, dial, wake, nil
+}
+
+// Put returns c of account to the pool.
+// The selected mailbox is unselected. c is closed if it is broken or the pool is closed.
+func (p *Pool) Put(account Account, c *Client) {
+	if c.connErr == nil && c.Mailbox() != nil {
+		c.Unselect()
+	}
+	if c.connErr != nil || c.Mailbox() != nil {
+		p.Discard(account, c)
+		return
+	}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		p.Discard(account, c)
+		return
+	}
+	p.idle[account] = append(p.idle[account], idleClient{c: c, since: time.Now()})
+	p.wakeLocked()
+	expired := p.expiredLocked()
+	p.mu.Unlock()
+
+	closeClients(expired)
+}
+
+// Discard closes c of account instead of returning it to the pool.
+func (p *Pool) Discard(account Account, c *Client) {
+	closeClients([]*Client{c})
+	p.release(account)
+}
+
+// CloseIdle closes all the idle connections.
+func (p *Pool) CloseIdle() {
+	p.mu.Lock()
+	var clients []*Client
+	for account, idle := range p.idle {
+		for _, ic := range idle {
+			clients = append(clients, ic.c)
+		}
+		p.open[account.Addr] -= len(idle)
+		delete(p.idle, account)
+	}
+	p.wakeLocked()
+	p.mu.Unlock()
+
+	closeClients(clients)
+}
+
+// Close closes the idle connections, and the others when they are returned.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.stop)
+	}
+	p.mu.Unlock()
+
+	p.CloseIdle()
+}
+
+// reap closes the expired connections every interval until Close.
+func (p *Pool) reap(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.mu.Lock()
+			expired := p.expiredLocked()
+			p.mu.Unlock()
+
+			closeClients(expired)
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// release frees the connection of account.
+func (p *Pool) release(account Account) {
+	p.mu.Lock()
+	p.open[account.Addr]--
+	p.wakeLocked()
+	p.mu.Unlock()
+}
+
+func (p *Pool) wakeLocked() {
+	close(p.wake)
+	p.wake = make(chan struct{})
+}
+
+// expiredLocked removes the connections idle longer than IdleTimeout and returns them.
+func (p *Pool) expiredLocked() []*Client {
+	if p.opts.IdleTimeout == 0 {
+		return nil
+	}
+
+	var expired []*Client
+	deadline := time.Now().Add(-p.opts.IdleTimeout)
+	for account, idle := range p.idle {
+		kept := idle[:0]
+		for _, ic := range idle {
+			if ic.since.Before(deadline) {
+				expired = append(expired, ic.c)
+				p.open[account.Addr]--
+			} else {
+				kept = append(kept, ic)
+			}
+		}
+		p.idle[account] = kept
+	}
+	if len(expired) > 0 {
+		p.wakeLocked()
+	}
+	return expired
+}
+
+// idleOfServerLocked removes an idle connection to addr and returns it, keeping it counted as open.
+func (p *Pool) idleOfServerLocked(addr string) *Client {
+	for account, idle := range p.idle {
+		if account.Addr == addr && len(idle) > 0 {
+			c := idle[0].c
+			p.idle[account] = idle[1:]
+			return c
+		}
+	}
+	return nil
+}
+
+// closeClients logs out and closes clients.
+func closeClients(clients []*Client) {
+	for _, c := range clients {
+		if c.connErr == nil {
+			c.Logout()
+		}
+		c.conn.Close()
+	}
+}
@@ -0,0 +1,319 @@
+package imapclient
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDialer dials the fake servers running scripts in order.
+type fakeDialer struct {
+	t       *testing.T
+	mu      sync.Mutex
+	scripts []func(s *fakeServer)
+	dials   int
+	dones   []<-chan struct{}
+}
+
+func (d *fakeDialer) dial(account Account) (*Client, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.dials >= len(d.scripts) {
+		return nil, fmt.Errorf("dial %v: unexpected", d.dials+1)
+	}
+	c, done := newFakeServer(d.t, d.scripts[d.dials])
+	c.caps = []string{"IMAP4rev1", "UNSELECT"}
+	d.dials++
+	d.dones = append(d.dones, done)
+	return c, nil
+}
+
+// wait waits for the servers dialed.
+func (d *fakeDialer) wait() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, done := range d.dones {
+		<-done
+	}
+}
+
+func (p *Pool) openCount(addr string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.open[addr]
+}
+
+func (p *Pool) idleCount(account Account) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.idle[account])
+}
+
+// logout plays LOGOUT of a closed client.
+func logout(tag string) func(s *fakeServer) {
+	return func(s *fakeServer) {
+		s.expect(tag + " LOGOUT")
+		s.send("* BYE Logging out", tag+" OK LOGOUT completed")
+	}
+}
+
+func TestPoolWait(t *testing.T) {
+	d := &fakeDialer{t: t, scripts: []func(s *fakeServer){
+		func(s *fakeServer) {
+			// the health check of the waiter woken by Put
+			s.expect("A1 NOOP")
+			s.send("A1 OK NOOP completed")
+			logout("A2")(s)
+		},
+		logout("A1"),
+	}}
+	p := NewPool(PoolOptions{Dial: d.dial, MaxPerServer: 1})
+	account := Account{Network: "tcp", Addr: "imap.example.com:993", Username: "alice"}
+
+	c1, err := p.Get(context.Background(), account)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := p.Get(ctx, account); err != context.DeadlineExceeded {
+		t.Errorf("Get on the limit %v, expected %v", err, context.DeadlineExceeded)
+	}
+
+	cases := []struct {
+		name    string
+		release func()
+		expect  int // dials
+	}{
+		{"Put", func() { p.Put(account, c1) }, 1},
+		{"Discard", func() { p.Discard(account, c1) }, 2},
+	}
+	for i, cs := range cases {
+		got := make(chan *Client)
+		go func() {
+			c, err := p.Get(context.Background(), account)
+			if err != nil {
+				t.Errorf("%v: Get: %v", i, err)
+			}
+			got <- c
+		}()
+
+		select {
+		case <-got:
+			t.Fatalf("%v: Get returned before %v", i, cs.name)
+		case <-time.After(20 * time.Millisecond):
+		}
+		cs.release()
+
+		select {
+		case c := <-got:
+			if c == nil {
+				t.FailNow()
+			}
+			c1 = c
+		case <-time.After(time.Second):
+			t.Fatalf("%v: Get was not woken by %v", i, cs.name)
+		}
+		if d.dials != cs.expect {
+			t.Errorf("%v: %v dials after %v, expected %v", i, d.dials, cs.name, cs.expect)
+		}
+		if n := p.openCount(account.Addr); n != 1 {
+			t.Errorf("%v: %v open after %v, expected 1", i, n, cs.name)
+		}
+	}
+
+	p.Put(account, c1)
+	p.Close()
+	if n := p.openCount(account.Addr); n != 0 {
+		t.Errorf("%v open after Close, expected 0", n)
+	}
+	d.wait()
+}
+
+func TestPoolHealthCheck(t *testing.T) {
+	d := &fakeDialer{t: t, scripts: []func(s *fakeServer){
+		func(s *fakeServer) {
+			// lost while idle
+		},
+		logout("A1"),
+	}}
+	p := NewPool(PoolOptions{Dial: d.dial, MaxPerServer: 1})
+	account := Account{Network: "tcp", Addr: "imap.example.com:993", Username: "alice"}
+
+	c1, err := p.Get(context.Background(), account)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	p.Put(account, c1)
+
+	c2, err := p.Get(context.Background(), account)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if c2 == c1 || d.dials != 2 {
+		t.Errorf("Get returned the broken client, or dialed %v times, expected a new client", d.dials)
+	}
+	if n := p.openCount(account.Addr); n != 1 {
+		t.Errorf("%v open, expected 1", n)
+	}
+
+	p.Discard(account, c2)
+	if n := p.openCount(account.Addr); n != 0 {
+		t.Errorf("%v open after Discard, expected 0", n)
+	}
+	p.Close()
+	d.wait()
+}
+
+func TestPoolPutUnselect(t *testing.T) {
+	cases := []struct {
+		response string
+		expect   int // idle
+	}{
+		{"A1 OK UNSELECT completed", 1},
+		{"A1 NO UNSELECT failed", 0},
+	}
+
+	for i, cs := range cases {
+		d := &fakeDialer{t: t, scripts: []func(s *fakeServer){
+			func(s *fakeServer) {
+				s.expect("A1 UNSELECT")
+				s.send(cs.response)
+				logout("A2")(s)
+			},
+		}}
+		p := NewPool(PoolOptions{Dial: d.dial})
+		account := Account{Network: "tcp", Addr: "imap.example.com:993", Username: "alice"}
+
+		c, err := p.Get(context.Background(), account)
+		if err != nil {
+			t.Fatalf("%v: Get: %v", i, err)
+		}
+		c.mailbox = &MailboxStatus{Name: "INBOX"}
+		p.Put(account, c)
+
+		if n := p.idleCount(account); n != cs.expect {
+			t.Errorf("%v: %v idle after Put, expected %v", i, n, cs.expect)
+		}
+		if n := p.openCount(account.Addr); n != cs.expect {
+			t.Errorf("%v: %v open after Put, expected %v", i, n, cs.expect)
+		}
+		if cs.expect == 1 && c.Mailbox() != nil {
+			t.Errorf("%v: Mailbox %+v after Put, expected nil", i, c.Mailbox())
+		}
+		p.Close()
+		d.wait()
+	}
+}
+
+func TestPoolEvict(t *testing.T) {
+	d := &fakeDialer{t: t, scripts: []func(s *fakeServer){
+		// evicted for bob
+		logout("A1"),
+		logout("A1"),
+	}}
+	p := NewPool(PoolOptions{Dial: d.dial, MaxPerServer: 1})
+	alice := Account{Network: "tcp", Addr: "imap.example.com:993", Username: "alice"}
+	bob := Account{Network: "tcp", Addr: "imap.example.com:993", Username: "bob"}
+
+	c1, err := p.Get(context.Background(), alice)
+	if err != nil {
+		t.Fatalf("Get(alice): %v", err)
+	}
+	p.Put(alice, c1)
+
+	c2, err := p.Get(context.Background(), bob)
+	if err != nil {
+		t.Fatalf("Get(bob): %v", err)
+	}
+	if c2 == c1 || d.dials != 2 {
+		t.Errorf("Get(bob) dialed %v times, expected a new client", d.dials)
+	}
+	if n := p.idleCount(alice); n != 0 {
+		t.Errorf("%v idle of alice, expected 0", n)
+	}
+	if n := p.openCount(alice.Addr); n != 1 {
+		t.Errorf("%v open, expected 1", n)
+	}
+
+	p.Put(bob, c2)
+	p.Close()
+	d.wait()
+}
+
+func TestPoolClose(t *testing.T) {
+	d := &fakeDialer{t: t, scripts: []func(s *fakeServer){
+		logout("A1"),
+		logout("A1"),
+		logout("A1"),
+	}}
+	p := NewPool(PoolOptions{Dial: d.dial})
+	alice := Account{Network: "tcp", Addr: "imap.example.com:993", Username: "alice"}
+	bob := Account{Network: "tcp", Addr: "mail.example.net:993", Username: "bob"}
+
+	var clients []*Client
+	for _, account := range []Account{alice, alice, bob} {
+		c, err := p.Get(context.Background(), account)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		clients = append(clients, c)
+	}
+	p.Put(alice, clients[0])
+	p.Put(bob, clients[2])
+
+	cases := []struct {
+		name   string
+		do     func()
+		expect [2]int // open of alice and bob
+	}{
+		{"Get", func() {}, [2]int{2, 1}},
+		{"CloseIdle", p.CloseIdle, [2]int{1, 0}},
+		{"Close", p.Close, [2]int{1, 0}},
+		{"Put after Close", func() { p.Put(alice, clients[1]) }, [2]int{0, 0}},
+		{"Close again", p.Close, [2]int{0, 0}},
+	}
+	for i, cs := range cases {
+		cs.do()
+		if open := [2]int{p.openCount(alice.Addr), p.openCount(bob.Addr)}; open != cs.expect {
+			t.Errorf("%v: open %v after %v, expected %v", i, open, cs.name, cs.expect)
+		}
+	}
+
+	if _, err := p.Get(context.Background(), alice); err == nil {
+		t.Errorf("Get after Close succeeded, expected an error")
+	}
+	d.wait()
+}
+
+func TestPoolReap(t *testing.T) {
+	d := &fakeDialer{t: t, scripts: []func(s *fakeServer){
+		logout("A1"),
+	}}
+	p := NewPool(PoolOptions{Dial: d.dial, IdleTimeout: 20 * time.Millisecond})
+	defer p.Close()
+	account := Account{Network: "tcp", Addr: "imap.example.com:993", Username: "alice"}
+
+	c, err := p.Get(context.Background(), account)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	p.Put(account, c)
+
+	// closed without Get or Put
+	deadline := time.Now().Add(time.Second)
+	for p.openCount(account.Addr) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("the idle connection was not closed after IdleTimeout")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := p.idleCount(account); n != 0 {
+		t.Errorf("%v idle after IdleTimeout, expected 0", n)
+	}
+	d.wait()
+}
@@ -0,0 +1,145 @@
+package imapclient
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// QuotaResource is a resource limited by QUOTA (RFC 9208).
+type QuotaResource string
+
+const (
+	QuotaStorage QuotaResource = "STORAGE" // in units of 1024 octets
+	QuotaMessage QuotaResource = "MESSAGE" // the number of messages
+	QuotaMailbox QuotaResource = "MAILBOX" // the number of mailboxes
+)
+
+// QuotaUsage is the usage and the limit of a resource.
+type QuotaUsage struct {
+	Usage uint64
+	Limit uint64
+}
+
+// Quota is the resources of a quota root.
+type Quota struct {
+	Root      string
+	Resources map[QuotaResource]QuotaUsage
+}
+
+// OverQuotaError is returned when the server refuses a command by [OVERQUOTA].
+type OverQuotaError struct {
+	// Response is the tagged response, such as "A5 NO [OVERQUOTA] Quota exceeded".
+	Response string
+}
+
+func (e *OverQuotaError) Error() string {
+	return e.Response
+}
+
+// overQuotaError returns err as OverQuotaError if it has [OVERQUOTA].
+func overQuotaError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, found := responseCode(err.Error(), "OVERQUOTA"); found {
+		return &OverQuotaError{Response: err.Error()}
+	}
+	return err
+}
+
+// GetQuotaRoot returns the quota roots of mailbox and their quotas (GETQUOTAROOT).
+func (c *Client) GetQuotaRoot(mailbox string) (roots []string, quotas []Quota, err error) {
+	mailbox, err = c.quoteMailbox(mailbox)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode mailbox: %v", err)
+	}
+	res, err := c.Command("GETQUOTAROOT " + mailbox)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, line := range untaggedLines(res, "QUOTAROOT") {
+		fields, err := parseFields(line[len("QUOTAROOT"):])
+		if err != nil || len(fields) == 0 {
+			return nil, nil, fmt.Errorf("failed to parse %q: %v", line, err)
+		}
+		for _, f := range fields[1:] {
+			roots = append(roots, fieldString(f))
+		}
+	}
+
+	quotas, err = parseQuotas(res)
+	if err != nil {
+		return nil, nil, err
+	}
+	return roots, quotas, nil
+}
+
+// GetQuota returns the quota of root (GETQUOTA).
+func (c *Client) GetQuota(root string) (*Quota, error) {
+	res, err := c.Command("GETQUOTA " + quoteString(root))
+	if err != nil {
+		return nil, err
+	}
+
+	quotas, err := parseQuotas(res)
+	if err != nil {
+		return nil, err
+	}
+	if len(quotas) == 0 {
+		return nil, fmt.Errorf("no QUOTA response")
+	}
+	return &quotas[0], nil
+}
+
+// SetQuota sets the limits of root (SETQUOTA).
+// The resources not in limits become unlimited.
+func (c *Client) SetQuota(root string, limits map[QuotaResource]uint64) error {
+	resources := make([]string, 0, len(limits))
+	for res := range limits {
+		resources = append(resources, string(res))
+	}
+	sort.Strings(resources)
+
+	pairs := make([]string, 0, len(limits))
+	for _, res := range resources {
+		pairs = append(pairs, fmt.Sprintf("%v %d", res, limits[QuotaResource(res)]))
+	}
+
+	_, err := c.Command(fmt.Sprintf("SETQUOTA %v (%v)", quoteString(root), strings.Join(pairs, " ")))
+	return err
+}
+
+// parseQuotas parses `QUOTA "" (STORAGE 10 512 MESSAGE 1 100)` in res.
+func parseQuotas(res string) ([]Quota, error) {
+	quotas := make([]Quota, 0, 1)
+	for _, line := range untaggedLines(res, "QUOTA") {
+		fields, err := parseFields(line[len("QUOTA"):])
+		if err != nil || len(fields) != 2 {
+			return nil, fmt.Errorf("failed to parse %q: %v", line, err)
+		}
+
+		q := Quota{
+			Root:      fieldString(fields[0]),
+			Resources: make(map[QuotaResource]QuotaUsage),
+		}
+		list := fieldList(fields[1])
+		if len(list)%3 != 0 {
+			return nil, fmt.Errorf("failed to parse %q", line)
+		}
+		for i := 0; i < len(list); i += 3 {
+			usage, err := fieldUint64(list[i+1])
+			if err != nil {
+				return nil, err
+			}
+			limit, err := fieldUint64(list[i+2])
+			if err != nil {
+				return nil, err
+			}
+			q.Resources[QuotaResource(strings.ToUpper(fieldString(list[i])))] = QuotaUsage{Usage: usage, Limit: limit}
+		}
+		quotas = append(quotas, q)
+	}
+	return quotas, nil
+}
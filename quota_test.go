@@ -0,0 +1,32 @@
+package imapclient
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseQuotas(t *testing.T) {
+	res := "* QUOTAROOT INBOX \"\"\r\n" +
+		"* QUOTA \"\" (STORAGE 10 512 MESSAGE 1 100)\r\n" +
+		"A003 OK Getquota completed\r\n"
+
+	quotas, err := parseQuotas(res)
+	if err != nil {
+		t.Fatalf("parseQuotas: %v", err)
+	}
+	if len(quotas) != 1 || quotas[0].Root != "" ||
+		quotas[0].Resources[QuotaStorage] != (QuotaUsage{10, 512}) || quotas[0].Resources[QuotaMessage] != (QuotaUsage{1, 100}) {
+		t.Errorf("unexpected quotas %#v", quotas)
+	}
+}
+
+func TestOverQuotaError(t *testing.T) {
+	err := overQuotaError(errors.New("A5 NO [OVERQUOTA] Quota exceeded"))
+	if _, ok := err.(*OverQuotaError); !ok {
+		t.Errorf("got %#v, want OverQuotaError", err)
+	}
+	err = overQuotaError(errors.New("A5 NO [TRYCREATE] No such mailbox"))
+	if _, ok := err.(*OverQuotaError); ok {
+		t.Errorf("got %#v, want an error other than OverQuotaError", err)
+	}
+}
@@ -0,0 +1,344 @@
+package imapclient
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// ReconnectOptions are the options of NewReconnectingClient.
+type ReconnectOptions struct {
+	Network string
+	Addr    string
+	Options *Options // for NewClientOptions
+
+	Username string
+	Password string
+	// TokenSource returns an OAuth2 access token, used by AUTHENTICATE XOAUTH2 instead of Password if not nil.
+	TokenSource func() (string, error)
+
+	// MaxRetries is the number of reconnections for a command, 3 if 0.
+	MaxRetries int
+	// Backoff is the wait before the first reconnection, doubled every retry. 1 second if 0.
+	Backoff time.Duration
+
+	// OnReconnect is called after every attempt of reconnection if not nil.
+	OnReconnect func(ReconnectEvent)
+}
+
+// ReconnectEvent reports an attempt of reconnection.
+type ReconnectEvent struct {
+	Attempt int   // 1 for the first attempt of a command
+	Err     error // nil if reconnected
+	// Mailbox is the mailbox selected again, or "".
+	Mailbox string
+	// Changes are the changes since the lost connection if the mailbox was selected with QResync, otherwise nil.
+	Changes *Changes
+}
+
+// ErrDisconnected is returned by Do without retry when the connection has been lost.
+var ErrDisconnected = errors.New("disconnected")
+
+// ReconnectingClient is a Client that connects again when the connection is lost.
+//
+// A command is run by Do, or by the methods of the read-only commands such as Fetch.
+// When the connection is lost, the client re-dials, authenticates, enables the capabilities enabled before,
+// and selects the mailbox selected before with the same SelectOptions. Then the read-only commands are retried.
+//
+// A ReconnectingClient is not safe for concurrent use by multiple goroutines.
+type ReconnectingClient struct {
+	opts ReconnectOptions
+	dial func() (*Client, error) // connects without authentication
+
+	c       *Client        // nil if disconnected
+	mailbox *MailboxStatus // selected by the lost connection
+	enabled []string       // enabled by the lost connection
+
+	selected   string        // the mailbox selected by SelectWith
+	selectOpts SelectOptions // the options of selected
+}
+
+// NewReconnectingClient connects and authenticates with opts.
+func NewReconnectingClient(opts ReconnectOptions) (*ReconnectingClient, error) {
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = 3
+	}
+	if opts.Backoff == 0 {
+		opts.Backoff = time.Second
+	}
+
+	rc := &ReconnectingClient{opts: opts}
+	rc.dial = func() (*Client, error) {
+		return NewClientOptions(opts.Network, opts.Addr, opts.Options)
+	}
+	c, err := rc.connect()
+	if err != nil {
+		return nil, err
+	}
+	rc.c = c
+	return rc, nil
+}
+
+// Client returns the current client, or nil if disconnected. It changes on reconnection.
+func (rc *ReconnectingClient) Client() *Client {
+	return rc.c
+}
+
+// Do calls fn with the client.
+// If fn fails because the connection is lost, the client reconnects with backoff,
+// and calls fn again if retry is true.
+// Pass retry false for commands not safe to repeat, such as Append and Expunge.
+// Without retry, Do never reconnects, and fails with ErrDisconnected if the connection has been lost;
+// the next Do with retry reconnects.
+func (rc *ReconnectingClient) Do(retry bool, fn func(c *Client) error) error {
+	if rc.c == nil && !retry {
+		return ErrDisconnected
+	}
+
+	var err error
+	backoff := rc.opts.Backoff
+	reconnects := 0
+	for attempt := 0; ; attempt++ {
+		if rc.c == nil {
+			reconnects++
+			rc.c, err = rc.reconnect(reconnects)
+		}
+		if rc.c != nil {
+			err = fn(rc.c)
+			if rc.c.connErr == nil {
+				return err
+			}
+			rc.disconnect()
+			if !retry {
+				return err
+			}
+		}
+
+		if attempt >= rc.opts.MaxRetries {
+			return err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// Select selects mailbox, and selects it again on reconnection.
+func (rc *ReconnectingClient) Select(mailbox string) error {
+	_, _, err := rc.SelectWith(mailbox, nil)
+	return err
+}
+
+// Examine examines mailbox, and examines it again on reconnection.
+func (rc *ReconnectingClient) Examine(mailbox string) error {
+	_, _, err := rc.SelectWith(mailbox, &SelectOptions{ReadOnly: true})
+	return err
+}
+
+// SelectWith selects mailbox with opts, and selects it again with opts on reconnection.
+// With opts.QResync, the reselection resynchronizes from the state of the lost connection,
+// and reports the changes by ReconnectEvent.Changes.
+func (rc *ReconnectingClient) SelectWith(mailbox string, opts *SelectOptions) (status *MailboxStatus, changes *Changes, err error) {
+	if opts == nil {
+		opts = &SelectOptions{}
+	}
+	err = rc.Do(true, func(c *Client) error {
+		status, changes, err = c.SelectWith(mailbox, opts)
+		return err
+	})
+	if err == nil {
+		rc.selected, rc.selectOpts = mailbox, *opts
+	}
+	return status, changes, err
+}
+
+func (rc *ReconnectingClient) Noop() error {
+	return rc.Do(true, func(c *Client) error { return c.Noop() })
+}
+
+func (rc *ReconnectingClient) List(reference, mailbox string) (items []ListItem, err error) {
+	err = rc.Do(true, func(c *Client) error {
+		items, err = c.List(reference, mailbox)
+		return err
+	})
+	return items, err
+}
+
+func (rc *ReconnectingClient) Status(mailbox string, itemNames []string) (st map[string]uint32, err error) {
+	err = rc.Do(true, func(c *Client) error {
+		st, err = c.Status(mailbox, itemNames)
+		return err
+	})
+	return st, err
+}
+
+func (rc *ReconnectingClient) Search(criteria string, optLiteral ...string) (ids []uint32, err error) {
+	err = rc.Do(true, func(c *Client) error {
+		ids, err = c.Search(criteria, optLiteral...)
+		return err
+	})
+	return ids, err
+}
+
+func (rc *ReconnectingClient) Fetch(seqSet string, optHeader ...bool) (mails map[uint32]*mail.Message, err error) {
+	err = rc.Do(true, func(c *Client) error {
+		mails, err = c.Fetch(seqSet, optHeader...)
+		return err
+	})
+	return mails, err
+}
+
+// Logout logs out and closes the connection.
+func (rc *ReconnectingClient) Logout() error {
+	if rc.c == nil {
+		return nil
+	}
+	err := rc.c.Logout()
+	rc.c.conn.Close()
+	rc.c = nil
+	rc.mailbox = nil
+	return err
+}
+
+// disconnect closes the lost connection, keeping the state to be restored.
+func (rc *ReconnectingClient) disconnect() {
+	rc.mailbox = rc.c.mailbox
+	rc.enabled = rc.enabled[:0]
+	for capa := range rc.c.enabled {
+		rc.enabled = append(rc.enabled, capa)
+	}
+	rc.c.conn.Close()
+	rc.c = nil
+}
+
+// reconnect connects and restores the state, reporting the result to OnReconnect.
+func (rc *ReconnectingClient) reconnect(reconnects int) (*Client, error) {
+	c, err := rc.connect()
+	if err == nil && len(rc.enabled) > 0 {
+		_, err = c.Enable(rc.enabled...)
+	}
+	var changes *Changes
+	if err == nil && rc.mailbox != nil {
+		_, changes, err = c.SelectWith(rc.mailbox.Name, rc.reselectOptions())
+	}
+	if err != nil && c != nil {
+		c.conn.Close()
+		c = nil
+	}
+
+	if rc.opts.OnReconnect != nil {
+		ev := ReconnectEvent{Attempt: reconnects, Err: err}
+		if err == nil && rc.mailbox != nil {
+			ev.Mailbox = rc.mailbox.Name
+			ev.Changes = changes
+		}
+		rc.opts.OnReconnect(ev)
+	}
+	return c, err
+}
+
+// reselectOptions returns the options to select the mailbox of the lost connection again.
+// The options of SelectWith are used if it selected the mailbox, with QResync from the lost state.
+func (rc *ReconnectingClient) reselectOptions() *SelectOptions {
+	if rc.mailbox.Name != rc.selected {
+		// selected by a command in Do
+		return &SelectOptions{ReadOnly: rc.mailbox.ReadOnly}
+	}
+
+	opts := rc.selectOpts
+	if opts.QResync != nil && rc.mailbox.UIDValidity != 0 && rc.mailbox.HighestModSeq != 0 {
+		opts.QResync = &QResyncParams{
+			UIDValidity: rc.mailbox.UIDValidity,
+			ModSeq:      rc.mailbox.HighestModSeq,
+			KnownUIDs:   opts.QResync.KnownUIDs,
+		}
+	}
+	return &opts
+}
+
+// connect dials and authenticates.
+func (rc *ReconnectingClient) connect() (*Client, error) {
+	c, err := rc.dial()
+	if err != nil {
+		return nil, err
+	}
+
+	if rc.opts.TokenSource != nil {
+		var token string
+		token, err = rc.opts.TokenSource()
+		if err != nil {
+			err = fmt.Errorf("failed to get token: %v", err)
+		} else {
+			err = authenticateXOAUTH2(c, rc.opts.Username, token)
+		}
+	} else {
+		err = c.Login(rc.opts.Username, rc.opts.Password)
+	}
+	if err != nil {
+		c.conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// authenticateXOAUTH2 authenticates by AUTHENTICATE XOAUTH2.
+// The initial response is sent with the command if the server supports SASL-IR (RFC 4959),
+// otherwise after the first continuation request.
+// A rejected token is reported by a continuation request with an error in JSON,
+// which is answered by an empty response to get the tagged NO.
+func authenticateXOAUTH2(c *Client, username, token string) error {
+	saslIR, err := c.HasCapability("SASL-IR")
+	if err != nil {
+		return err
+	}
+
+	tag := c.makeNewTag()
+	cmd := "AUTHENTICATE XOAUTH2"
+	sent := false
+	if saslIR {
+		cmd += " " + xoauth2(username, token)
+		sent = true
+	}
+	if err := c.write(tag + " " + cmd + "\r\n"); err != nil {
+		return err
+	}
+
+	var challenge string
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return fmt.Errorf("failed to scan result: %v", err)
+		}
+
+		switch {
+		case strings.HasPrefix(line, "+"):
+			response := ""
+			if !sent {
+				response = xoauth2(username, token)
+				sent = true
+			} else {
+				challenge = strings.TrimSpace(line[1:])
+			}
+			if err := c.write(response + "\r\n"); err != nil {
+				return err
+			}
+
+		case strings.HasPrefix(line, tag+" "):
+			if !strings.HasPrefix(strings.ToUpper(line[len(tag)+1:]), "OK") {
+				if decoded, err := base64.StdEncoding.DecodeString(challenge); err == nil && len(decoded) > 0 {
+					return fmt.Errorf("%v: %s", line, decoded)
+				}
+				return fmt.Errorf("%v", line)
+			}
+			c.caps = nil
+			return nil
+		}
+	}
+}
+
+// xoauth2 returns the initial response of XOAUTH2.
+func xoauth2(username, token string) string {
+	return base64.StdEncoding.EncodeToString([]byte("user=" + username + "\x01auth=Bearer " + token + "\x01\x01"))
+}
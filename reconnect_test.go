@@ -0,0 +1,333 @@
+package imapclient
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestReconnectingClient returns a ReconnectingClient using c,
+// which dials the fake servers running scripts in order, or fails when they run out.
+// The returned func waits for the servers dialed.
+func newTestReconnectingClient(t *testing.T, c *Client, opts ReconnectOptions, scripts ...func(s *fakeServer)) (*ReconnectingClient, func()) {
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = 3
+	}
+	opts.Backoff = time.Millisecond
+	opts.Username, opts.Password = "u", "p"
+
+	var mu sync.Mutex
+	var dones []<-chan struct{}
+	rc := &ReconnectingClient{opts: opts, c: c}
+	rc.dial = func() (*Client, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(scripts) == 0 {
+			return nil, fmt.Errorf("connection refused")
+		}
+		c, done := newFakeServer(t, scripts[0])
+		scripts = scripts[1:]
+		dones = append(dones, done)
+		return c, nil
+	}
+	return rc, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, done := range dones {
+			<-done
+		}
+	}
+}
+
+func TestReconnectRetry(t *testing.T) {
+	c, done := newFakeServer(t, func(s *fakeServer) {
+		// lost during SEARCH
+		s.expect("A1 SEARCH ALL")
+	})
+	c.mailbox = &MailboxStatus{Name: "INBOX"}
+	c.enabled = map[string]bool{"UTF8=ACCEPT": true}
+
+	var events []ReconnectEvent
+	rc, wait := newTestReconnectingClient(t, c, ReconnectOptions{OnReconnect: func(ev ReconnectEvent) { events = append(events, ev) }},
+		func(s *fakeServer) {
+			s.expect("A1 LOGIN u p")
+			s.send("A1 OK LOGIN completed")
+			s.expect("A2 ENABLE UTF8=ACCEPT")
+			s.send("* ENABLED UTF8=ACCEPT", "A2 OK ENABLE completed")
+			s.expect(`A3 SELECT "INBOX"`)
+			s.send("* 2 EXISTS", "A3 OK [READ-WRITE] SELECT completed")
+			s.expect("A4 SEARCH ALL")
+			s.send("* SEARCH 1 2", "A4 OK SEARCH completed")
+		})
+
+	calls := 0
+	var ids []uint32
+	err := rc.Do(true, func(c *Client) error {
+		calls++
+		var err error
+		ids, err = c.Search("ALL")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	<-done
+	wait()
+
+	if calls != 2 || fmt.Sprint(ids) != "[1 2]" {
+		t.Errorf("Do called fn %v times, returning %v, expected 2 times and [1 2]", calls, ids)
+	}
+	if len(events) != 1 || events[0] != (ReconnectEvent{Attempt: 1, Mailbox: "INBOX"}) {
+		t.Errorf("OnReconnect %+v, expected an attempt reselecting INBOX", events)
+	}
+	if mbox := rc.Client().Mailbox(); mbox == nil || mbox.Name != "INBOX" || mbox.Exists != 2 {
+		t.Errorf("Mailbox %+v after reconnection", mbox)
+	}
+	if !rc.Client().Enabled("UTF8=ACCEPT") {
+		t.Errorf("UTF8=ACCEPT is not enabled after reconnection")
+	}
+}
+
+func TestReconnectNoRetry(t *testing.T) {
+	c, done := newFakeServer(t, func(s *fakeServer) {
+		s.expect("A1 EXPUNGE")
+	})
+	c.mailbox = &MailboxStatus{Name: "INBOX"}
+
+	var events []ReconnectEvent
+	rc, wait := newTestReconnectingClient(t, c, ReconnectOptions{OnReconnect: func(ev ReconnectEvent) { events = append(events, ev) }},
+		func(s *fakeServer) {
+			s.expect("A1 LOGIN u p")
+			s.send("A1 OK LOGIN completed")
+			s.expect(`A2 SELECT "INBOX"`)
+			s.send("A2 OK [READ-WRITE] SELECT completed")
+			s.expect("A3 NOOP")
+			s.send("A3 OK NOOP completed")
+		})
+
+	calls := 0
+	err := rc.Do(false, func(c *Client) error {
+		calls++
+		return c.Expunge()
+	})
+	if err == nil {
+		t.Errorf("Do succeeded, expected the error of the lost connection")
+	}
+	<-done
+	if calls != 1 || rc.Client() != nil || len(events) != 0 {
+		t.Errorf("Do called fn %v times, client %v, events %+v, expected once without reconnection", calls, rc.Client(), events)
+	}
+
+	// without retry, it does not reconnect either
+	err = rc.Do(false, func(c *Client) error {
+		calls++
+		return c.Expunge()
+	})
+	if err != ErrDisconnected || calls != 1 || len(events) != 0 {
+		t.Errorf("Do %v, called fn %v times, events %+v, expected %v without reconnection", err, calls, events, ErrDisconnected)
+	}
+
+	// the next command reconnects
+	if err := rc.Noop(); err != nil {
+		t.Fatalf("Noop: %v", err)
+	}
+	wait()
+	if len(events) != 1 || events[0] != (ReconnectEvent{Attempt: 1, Mailbox: "INBOX"}) {
+		t.Errorf("OnReconnect %+v, expected an attempt reselecting INBOX", events)
+	}
+}
+
+func TestReconnectSelectOptions(t *testing.T) {
+	cases := []struct {
+		opts     *SelectOptions
+		cmd      string   // the first selection
+		selected []string // the responses to cmd
+		reselect []string // the commands after LOGIN on reconnection
+		vanished string   // ReconnectEvent.Changes.Vanished, or "" if nil
+	}{
+		{
+			&SelectOptions{ReadOnly: true},
+			`A1 EXAMINE "INBOX"`,
+			[]string{"* 2 EXISTS", "A1 OK [READ-ONLY] EXAMINE completed"},
+			[]string{`A2 EXAMINE "INBOX"`},
+			"",
+		},
+		{
+			&SelectOptions{CondStore: true},
+			`A1 SELECT "INBOX" (CONDSTORE)`,
+			[]string{"* OK [HIGHESTMODSEQ 120] Highest", "A1 OK [READ-WRITE] SELECT completed"},
+			[]string{`A2 SELECT "INBOX" (CONDSTORE)`},
+			"",
+		},
+		{
+			&SelectOptions{QResync: &QResyncParams{UIDValidity: 1, ModSeq: 50}},
+			`A1 SELECT "INBOX" (QRESYNC (1 50))`,
+			[]string{"* OK [UIDVALIDITY 1] UIDs valid", "* OK [HIGHESTMODSEQ 120] Highest", "* VANISHED (EARLIER) 3", "A1 OK [READ-WRITE] SELECT completed"},
+			[]string{"A2 ENABLE QRESYNC", `A3 SELECT "INBOX" (QRESYNC (1 120))`},
+			"5",
+		},
+	}
+
+	for i, cs := range cases {
+		c, done := newFakeServer(t, func(s *fakeServer) {
+			s.expect(cs.cmd)
+			s.send(cs.selected...)
+			// lost during NOOP
+			s.expect("A2 NOOP")
+		})
+		if cs.opts.QResync != nil {
+			c.enabled = map[string]bool{"QRESYNC": true}
+		}
+
+		var events []ReconnectEvent
+		rc, wait := newTestReconnectingClient(t, c, ReconnectOptions{OnReconnect: func(ev ReconnectEvent) { events = append(events, ev) }},
+			func(s *fakeServer) {
+				s.expect("A1 LOGIN u p")
+				s.send("A1 OK LOGIN completed")
+				for n, cmd := range cs.reselect {
+					s.expect(cmd)
+					if strings.Contains(cmd, "ENABLE") {
+						s.send("* ENABLED QRESYNC", fmt.Sprintf("A%v OK ENABLE completed", n+2))
+						continue
+					}
+					s.send("* VANISHED (EARLIER) 5", fmt.Sprintf("A%v OK SELECT completed", n+2))
+				}
+				s.expect(fmt.Sprintf("A%v NOOP", len(cs.reselect)+2))
+				s.send(fmt.Sprintf("A%v OK NOOP completed", len(cs.reselect)+2))
+			})
+
+		if _, _, err := rc.SelectWith("INBOX", cs.opts); err != nil {
+			t.Fatalf("%v: SelectWith: %v", i, err)
+		}
+		if err := rc.Noop(); err != nil {
+			t.Fatalf("%v: Noop: %v", i, err)
+		}
+		<-done
+		wait()
+
+		if len(events) != 1 || events[0].Err != nil || events[0].Mailbox != "INBOX" {
+			t.Errorf("%v: OnReconnect %+v, expected an attempt reselecting INBOX", i, events)
+			continue
+		}
+		if changes := events[0].Changes; (changes != nil) != (cs.vanished != "") {
+			t.Errorf("%v: Changes %+v, expected for QRESYNC only", i, changes)
+		} else if changes != nil && changes.Vanished.String() != cs.vanished {
+			t.Errorf("%v: Vanished %v, expected %v", i, changes.Vanished.String(), cs.vanished)
+		}
+	}
+}
+
+func TestReconnectMaxRetries(t *testing.T) {
+	c, done := newFakeServer(t, func(s *fakeServer) {
+		s.expect("A1 NOOP")
+	})
+
+	var events []ReconnectEvent
+	rc, wait := newTestReconnectingClient(t, c, ReconnectOptions{MaxRetries: 3, OnReconnect: func(ev ReconnectEvent) { events = append(events, ev) }},
+		func(s *fakeServer) {
+			s.expect("A1 LOGIN u p")
+			s.send("A1 NO [AUTHENTICATIONFAILED] Invalid credentials")
+		})
+
+	calls := 0
+	err := rc.Do(true, func(c *Client) error {
+		calls++
+		return c.Noop()
+	})
+	if err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Errorf("Do %v, expected the error of the last attempt", err)
+	}
+	<-done
+	wait()
+
+	if calls != 1 || rc.Client() != nil {
+		t.Errorf("Do called fn %v times, client %v, expected once without reconnection", calls, rc.Client())
+	}
+
+	cases := []struct {
+		expect string
+	}{
+		{"A1 NO [AUTHENTICATIONFAILED] Invalid credentials"},
+		{"connection refused"},
+		{"connection refused"},
+	}
+	if len(events) != len(cases) {
+		t.Fatalf("OnReconnect %v times, expected %v", len(events), len(cases))
+	}
+	for i, cs := range cases {
+		if ev := events[i]; ev.Attempt != i+1 || ev.Err == nil || ev.Err.Error() != cs.expect {
+			t.Errorf("%v: OnReconnect %+v, expected attempt %v with %q", i, ev, i+1, cs.expect)
+		}
+	}
+}
+
+func TestAuthenticateXOAUTH2(t *testing.T) {
+	ir := base64.StdEncoding.EncodeToString([]byte("user=u@example.com\x01auth=Bearer token\x01\x01"))
+	rejection := base64.StdEncoding.EncodeToString([]byte(`{"status":"401","schemes":"bearer"}`))
+
+	cases := []struct {
+		caps   []string
+		script func(s *fakeServer)
+		expect string // the error, or ""
+	}{
+		{
+			[]string{"IMAP4rev1", "SASL-IR", "AUTH=XOAUTH2"},
+			func(s *fakeServer) {
+				s.expect("A1 AUTHENTICATE XOAUTH2 " + ir)
+				s.send("A1 OK Success")
+			},
+			"",
+		},
+		{
+			[]string{"IMAP4rev1", "AUTH=XOAUTH2"},
+			func(s *fakeServer) {
+				s.expect("A1 AUTHENTICATE XOAUTH2")
+				s.send("+ ")
+				s.expect(ir)
+				s.send("A1 OK Success")
+			},
+			"",
+		},
+		{
+			[]string{"IMAP4rev1", "SASL-IR", "AUTH=XOAUTH2"},
+			func(s *fakeServer) {
+				s.expect("A1 AUTHENTICATE XOAUTH2 " + ir)
+				s.send("+ " + rejection)
+				s.expect("")
+				s.send("A1 NO [AUTHENTICATIONFAILED] Invalid credentials")
+			},
+			`A1 NO [AUTHENTICATIONFAILED] Invalid credentials: {"status":"401","schemes":"bearer"}`,
+		},
+		{
+			[]string{"IMAP4rev1", "AUTH=XOAUTH2"},
+			func(s *fakeServer) {
+				s.expect("A1 AUTHENTICATE XOAUTH2")
+				s.send("+ ")
+				s.expect(ir)
+				s.send("+ " + rejection)
+				s.expect("")
+				s.send("A1 NO Invalid credentials")
+			},
+			`A1 NO Invalid credentials: {"status":"401","schemes":"bearer"}`,
+		},
+	}
+
+	for i, cs := range cases {
+		c, done := newFakeServer(t, cs.script)
+		c.caps = cs.caps
+
+		err := authenticateXOAUTH2(c, "u@example.com", "token")
+		if cs.expect == "" && err != nil {
+			t.Errorf("%v: authenticateXOAUTH2: %v", i, err)
+		} else if cs.expect != "" && (err == nil || err.Error() != cs.expect) {
+			t.Errorf("%v: authenticateXOAUTH2 %v, expected %q", i, err, cs.expect)
+		}
+		if err == nil && c.caps != nil {
+			t.Errorf("%v: capabilities %v after authentication, expected to be asked again", i, c.caps)
+		}
+		<-done
+	}
+}
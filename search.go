@@ -0,0 +1,372 @@
+package imapclient
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SearchCriteria is a set of search keys for SEARCH.
+// Keys are ANDed together as IMAP does; use Not and Or to negate or combine them.
+//
+//	sc := NewSearchCriteria().NoFlag(FlagSeen).Since(t).From("someone@example.com")
+type SearchCriteria struct {
+	keys []searchKey
+}
+
+type searchKey struct {
+	name  string            // SEARCH key, e.g. "SINCE"
+	atoms []string          // arguments sent as they are
+	strs  []string          // arguments sent as quoted strings or literals
+	subs  []*SearchCriteria // operands of NOT and OR
+}
+
+const searchDateLayout = "2-Jan-2006"
+
+var searchFlagKeys = map[string]string{
+	FlagSeen:     "SEEN",
+	FlagAnswered: "ANSWERED",
+	FlagFlagged:  "FLAGGED",
+	FlagDeleted:  "DELETED",
+	FlagDraft:    "DRAFT",
+	FlagRecent:   "RECENT",
+}
+
+func NewSearchCriteria() *SearchCriteria {
+	return &SearchCriteria{}
+}
+
+func (sc *SearchCriteria) add(k searchKey) *SearchCriteria {
+	sc.keys = append(sc.keys, k)
+	return sc
+}
+
+func (sc *SearchCriteria) All() *SearchCriteria {
+	return sc.add(searchKey{name: "ALL"})
+}
+
+// Flag matches messages that have all of flags.
+// System flags map to their own keys (\Seen to SEEN), others to KEYWORD.
+func (sc *SearchCriteria) Flag(flags ...string) *SearchCriteria {
+	for _, f := range flags {
+		if k, found := searchFlagKeys[f]; found {
+			sc.add(searchKey{name: k})
+		} else {
+			sc.Keyword(f)
+		}
+	}
+	return sc
+}
+
+// NoFlag matches messages that have none of flags.
+// System flags map to their negative keys (\Seen to UNSEEN, \Recent to OLD), others to UNKEYWORD.
+func (sc *SearchCriteria) NoFlag(flags ...string) *SearchCriteria {
+	for _, f := range flags {
+		k, found := searchFlagKeys[f]
+		switch {
+		case !found:
+			sc.Unkeyword(f)
+		case k == "RECENT":
+			sc.add(searchKey{name: "OLD"})
+		default:
+			sc.add(searchKey{name: "UN" + k})
+		}
+	}
+	return sc
+}
+
+func (sc *SearchCriteria) Keyword(keyword string) *SearchCriteria {
+	return sc.add(searchKey{name: "KEYWORD", atoms: []string{keyword}})
+}
+
+func (sc *SearchCriteria) Unkeyword(keyword string) *SearchCriteria {
+	return sc.add(searchKey{name: "UNKEYWORD", atoms: []string{keyword}})
+}
+
+// Since matches messages whose internal date is on or after the date of t.
+func (sc *SearchCriteria) Since(t time.Time) *SearchCriteria {
+	return sc.date("SINCE", t)
+}
+
+// Before matches messages whose internal date is earlier than the date of t.
+func (sc *SearchCriteria) Before(t time.Time) *SearchCriteria {
+	return sc.date("BEFORE", t)
+}
+
+// On matches messages whose internal date is the date of t.
+func (sc *SearchCriteria) On(t time.Time) *SearchCriteria {
+	return sc.date("ON", t)
+}
+
+// SentSince matches messages whose Date: header is on or after the date of t.
+func (sc *SearchCriteria) SentSince(t time.Time) *SearchCriteria {
+	return sc.date("SENTSINCE", t)
+}
+
+// SentBefore matches messages whose Date: header is earlier than the date of t.
+func (sc *SearchCriteria) SentBefore(t time.Time) *SearchCriteria {
+	return sc.date("SENTBEFORE", t)
+}
+
+// SentOn matches messages whose Date: header is the date of t.
+func (sc *SearchCriteria) SentOn(t time.Time) *SearchCriteria {
+	return sc.date("SENTON", t)
+}
+
+func (sc *SearchCriteria) date(name string, t time.Time) *SearchCriteria {
+	return sc.add(searchKey{name: name, atoms: []string{t.Format(searchDateLayout)}})
+}
+
+// Header matches messages that have the header field containing value.
+// An empty value matches all messages that have the field.
+func (sc *SearchCriteria) Header(field, value string) *SearchCriteria {
+	return sc.add(searchKey{name: "HEADER", strs: []string{field, value}})
+}
+
+func (sc *SearchCriteria) From(value string) *SearchCriteria {
+	return sc.add(searchKey{name: "FROM", strs: []string{value}})
+}
+
+func (sc *SearchCriteria) To(value string) *SearchCriteria {
+	return sc.add(searchKey{name: "TO", strs: []string{value}})
+}
+
+func (sc *SearchCriteria) Cc(value string) *SearchCriteria {
+	return sc.add(searchKey{name: "CC", strs: []string{value}})
+}
+
+func (sc *SearchCriteria) Bcc(value string) *SearchCriteria {
+	return sc.add(searchKey{name: "BCC", strs: []string{value}})
+}
+
+func (sc *SearchCriteria) Subject(value string) *SearchCriteria {
+	return sc.add(searchKey{name: "SUBJECT", strs: []string{value}})
+}
+
+func (sc *SearchCriteria) Body(value string) *SearchCriteria {
+	return sc.add(searchKey{name: "BODY", strs: []string{value}})
+}
+
+// Text matches messages that contain value in the header or the body.
+func (sc *SearchCriteria) Text(value string) *SearchCriteria {
+	return sc.add(searchKey{name: "TEXT", strs: []string{value}})
+}
+
+// Larger matches messages larger than size octets.
+func (sc *SearchCriteria) Larger(size uint32) *SearchCriteria {
+	return sc.add(searchKey{name: "LARGER", atoms: []string{fmt.Sprint(size)}})
+}
+
+// Smaller matches messages smaller than size octets.
+func (sc *SearchCriteria) Smaller(size uint32) *SearchCriteria {
+	return sc.add(searchKey{name: "SMALLER", atoms: []string{fmt.Sprint(size)}})
+}
+
+// UID matches messages whose UIDs are in uidSet ("1:5,7").
+func (sc *SearchCriteria) UID(uidSet string) *SearchCriteria {
+	return sc.add(searchKey{name: "UID", atoms: []string{uidSet}})
+}
+
+// SeqSet matches messages whose sequence numbers are in seqSet ("1:5,7").
+func (sc *SearchCriteria) SeqSet(seqSet string) *SearchCriteria {
+	return sc.add(searchKey{atoms: []string{seqSet}})
+}
+
+// Not matches messages that do not match not.
+func (sc *SearchCriteria) Not(not *SearchCriteria) *SearchCriteria {
+	return sc.add(searchKey{name: "NOT", subs: []*SearchCriteria{not}})
+}
+
+// Or matches messages that match either a or b.
+func (sc *SearchCriteria) Or(a, b *SearchCriteria) *SearchCriteria {
+	return sc.add(searchKey{name: "OR", subs: []*SearchCriteria{a, b}})
+}
+
+// String returns the criteria as they are sent, literals included.
+func (sc *SearchCriteria) String() string {
+	lc := newLiteralCommand("")
+	sc.appendTo(lc)
+	return lc.String()
+}
+
+// needsCharset reports whether any string can not be sent as US-ASCII.
+func (sc *SearchCriteria) needsCharset() bool {
+	if sc == nil {
+		return false
+	}
+	for _, k := range sc.keys {
+		for _, s := range k.strs {
+			if !isASCII(s) {
+				return true
+			}
+		}
+		for _, sub := range k.subs {
+			if sub.needsCharset() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (sc *SearchCriteria) appendTo(lc *literalCommand) {
+	if sc == nil || len(sc.keys) == 0 {
+		lc.text("ALL")
+		return
+	}
+
+	for i, k := range sc.keys {
+		if i > 0 {
+			lc.text(" ")
+		}
+
+		args := make([]string, 0, 1+len(k.atoms))
+		if k.name != "" {
+			args = append(args, k.name)
+		}
+		args = append(args, k.atoms...)
+		lc.text(strings.Join(args, " "))
+
+		for _, s := range k.strs {
+			lc.text(" ")
+			lc.astring(s)
+		}
+
+		for _, sub := range k.subs {
+			lc.text(" ")
+			if sub != nil && len(sub.keys) > 1 {
+				lc.text("(")
+				sub.appendTo(lc)
+				lc.text(")")
+			} else {
+				sub.appendTo(lc)
+			}
+		}
+	}
+}
+
+// SearchWith searches the selected mailbox by criteria and returns sequence numbers.
+// Strings that are not US-ASCII are sent as UTF-8 literals.
+func (c *Client) SearchWith(criteria *SearchCriteria) ([]uint32, error) {
+	lc := newLiteralCommand("SEARCH ")
+	if criteria.needsCharset() {
+		lc.text("CHARSET UTF-8 ")
+	}
+	criteria.appendTo(lc)
+
+	res, err := c.commandLiterals(lc)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseSearchResponse(res)
+}
+
+// SearchReturn is a RETURN option of ESEARCH (RFC 4731).
+type SearchReturn string
+
+const (
+	SearchReturnMin   SearchReturn = "MIN"
+	SearchReturnMax   SearchReturn = "MAX"
+	SearchReturnCount SearchReturn = "COUNT"
+	SearchReturnAll   SearchReturn = "ALL"
+	// SearchReturnSave saves the result on the server to be referred as "$".
+	SearchReturnSave SearchReturn = "SAVE"
+)
+
+// ESearchResult is the result of ESearch.
+// Only the values asked by the RETURN options are set.
+// Min and Max are 0 if nothing matched.
+type ESearchResult struct {
+	UID   bool
+	Min   uint32
+	Max   uint32
+	Count uint32
+	All   SeqSet
+}
+
+// ESearch searches the selected mailbox with ESEARCH (RFC 4731).
+// Without returns, ALL is returned.
+// It fails if the server does not support ESEARCH; use Search instead.
+func (c *Client) ESearch(criteria *SearchCriteria, returns ...SearchReturn) (*ESearchResult, error) {
+	return c.esearch("SEARCH", criteria, returns)
+}
+
+// UIDESearch is ESearch returning UIDs instead of sequence numbers.
+func (c *Client) UIDESearch(criteria *SearchCriteria, returns ...SearchReturn) (*ESearchResult, error) {
+	return c.esearch("UID SEARCH", criteria, returns)
+}
+
+func (c *Client) esearch(cmd string, criteria *SearchCriteria, returns []SearchReturn) (*ESearchResult, error) {
+	hasESearch, err := c.HasCapability("ESEARCH")
+	if err != nil {
+		return nil, err
+	}
+	if !hasESearch {
+		return nil, fmt.Errorf("the server does not support ESEARCH")
+	}
+
+	if len(returns) == 0 {
+		returns = []SearchReturn{SearchReturnAll}
+	}
+	rets := make([]string, 0, len(returns))
+	for _, r := range returns {
+		rets = append(rets, string(r))
+	}
+
+	lc := newLiteralCommand(fmt.Sprintf("%s RETURN (%s) ", cmd, strings.Join(rets, " ")))
+	if criteria.needsCharset() {
+		lc.text("CHARSET UTF-8 ")
+	}
+	criteria.appendTo(lc)
+
+	res, err := c.commandLiterals(lc)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ESearchResult{}
+	for _, line := range untaggedLines(res, "ESEARCH") {
+		if err := result.parse(line[len("ESEARCH"):]); err != nil {
+			return nil, fmt.Errorf("failed to parse ESEARCH: %v", err)
+		}
+	}
+	return result, nil
+}
+
+func (r *ESearchResult) parse(line string) error {
+	fields, err := parseFields(line)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < len(fields); i++ {
+		name := fieldString(fields[i])
+		if name == "" {
+			continue // (TAG "A1")
+		}
+		if strings.EqualFold(name, "UID") {
+			r.UID = true
+			continue
+		}
+
+		i++
+		if i >= len(fields) {
+			return fmt.Errorf("missing value of %v", name)
+		}
+		switch strings.ToUpper(name) {
+		case "MIN":
+			r.Min, err = fieldUint32(fields[i])
+		case "MAX":
+			r.Max, err = fieldUint32(fields[i])
+		case "COUNT":
+			r.Count, err = fieldUint32(fields[i])
+		case "ALL":
+			r.All, err = ParseSeqSet(fieldString(fields[i]))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
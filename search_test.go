@@ -0,0 +1,120 @@
+package imapclient
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSearchCriteria(t *testing.T) {
+	since := time.Date(2017, 3, 4, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		sc      *SearchCriteria
+		expect  string
+		charset bool
+	}{
+		{NewSearchCriteria(), "ALL", false},
+		{NewSearchCriteria().Flag(FlagSeen, "$Important").NoFlag(FlagRecent, FlagDeleted), "SEEN KEYWORD $Important OLD UNDELETED", false},
+		{NewSearchCriteria().Since(since).SentBefore(since.AddDate(0, 0, 10)), "SINCE 4-Mar-2017 SENTBEFORE 14-Mar-2017", false},
+		{NewSearchCriteria().Header("X-Mailer", `a "b"`).Larger(1024), `HEADER "X-Mailer" "a \"b\"" LARGER 1024`, false},
+		{NewSearchCriteria().UID("1:5,7").SeqSet("2:*"), "UID 1:5,7 2:*", false},
+		{
+			NewSearchCriteria().Not(NewSearchCriteria().From("a").To("b")).Or(NewSearchCriteria().Flag(FlagFlagged), NewSearchCriteria().Subject("x")),
+			`NOT (FROM "a" TO "b") OR FLAGGED SUBJECT "x"`,
+			false,
+		},
+		{NewSearchCriteria().Subject("日本語").From("a"), "SUBJECT {9}\r\n日本語 FROM \"a\"", true},
+		{NewSearchCriteria().Not(NewSearchCriteria().Body("é")), "NOT BODY {2}\r\né", true},
+	}
+
+	for i, c := range cases {
+		if s := c.sc.String(); s != c.expect {
+			t.Errorf("%v: String() %q, expected %q", i, s, c.expect)
+		}
+		if cs := c.sc.needsCharset(); cs != c.charset {
+			t.Errorf("%v: needsCharset() %v, expected %v", i, cs, c.charset)
+		}
+	}
+}
+
+func TestESearchResultParse(t *testing.T) {
+	r := &ESearchResult{}
+	err := r.parse(` (TAG "A282") UID MIN 2 MAX 11 COUNT 3 ALL 2,10:11`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if !r.UID || r.Min != 2 || r.Max != 11 || r.Count != 3 || r.All.String() != "2,10:11" {
+		t.Errorf("unexpected result %#v", r)
+	}
+}
+
+func TestESearch(t *testing.T) {
+	all, _ := ParseSeqSet("2,10:11")
+
+	cases := []struct {
+		uid     bool
+		sc      *SearchCriteria
+		returns []SearchReturn
+		cmd     string // "" if refused
+		res     string
+		expect  ESearchResult
+	}{
+		{
+			false, NewSearchCriteria().NoFlag(FlagSeen), nil,
+			"A2 SEARCH RETURN (ALL) UNSEEN", `* ESEARCH (TAG "A2") ALL 2,10:11`,
+			ESearchResult{All: all},
+		},
+		{
+			true, NewSearchCriteria().All(), []SearchReturn{SearchReturnMin, SearchReturnCount},
+			"A2 UID SEARCH RETURN (MIN COUNT) ALL", `* ESEARCH (TAG "A2") UID MIN 2 COUNT 3`,
+			ESearchResult{UID: true, Min: 2, Count: 3},
+		},
+		{
+			false, NewSearchCriteria().Subject("日本語"), []SearchReturn{SearchReturnSave},
+			"A2 SEARCH RETURN (SAVE) CHARSET UTF-8 SUBJECT {9}", "",
+			ESearchResult{},
+		},
+		{false, NewSearchCriteria().All(), nil, "", "", ESearchResult{}},
+	}
+
+	for i, cs := range cases {
+		c, done := newFakeServer(t, func(s *fakeServer) {
+			s.expect("A1 CAPABILITY")
+			if cs.cmd == "" {
+				s.send("* CAPABILITY IMAP4rev1", "A1 OK CAPABILITY completed")
+				return
+			}
+			s.send("* CAPABILITY IMAP4rev1 ESEARCH", "A1 OK CAPABILITY completed")
+			s.expect(cs.cmd)
+			if cs.res == "" {
+				// the literal of the criteria
+				s.send("+ Ready")
+				s.read(len("日本語"))
+				s.expect("")
+			} else {
+				s.send(cs.res)
+			}
+			s.send("A2 OK SEARCH completed")
+		})
+		c.mailbox = &MailboxStatus{Name: "INBOX"}
+
+		var r *ESearchResult
+		var err error
+		if cs.uid {
+			r, err = c.UIDESearch(cs.sc, cs.returns...)
+		} else {
+			r, err = c.ESearch(cs.sc, cs.returns...)
+		}
+		<-done
+
+		switch {
+		case cs.cmd == "" && err == nil:
+			t.Errorf("%v: ESearch succeeded without ESEARCH, expected an error", i)
+		case cs.cmd != "" && err != nil:
+			t.Errorf("%v: ESearch: %v", i, err)
+		case err == nil && !reflect.DeepEqual(*r, cs.expect):
+			t.Errorf("%v: ESearch %#v, expected %#v", i, *r, cs.expect)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package imapclient
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SeqSet is a set of sequence numbers or UIDs, such as "1:5,7,10:*".
+// The zero value is an empty set.
+type SeqSet struct {
+	ranges []seqRange // sorted, neither overlapping nor adjacent
+}
+
+type seqRange struct {
+	start, stop uint32 // seqStar for *
+}
+
+// seqStar is "*", the largest number in use.
+const seqStar = math.MaxUint32
+
+// maxSeqSetLength is the longest sequence set sent in one command.
+// Servers limit the length of a command line (RFC 7162 recommends at least 8192 octets).
+const maxSeqSetLength = 1000
+
+// NewSeqSet returns a set of nums.
+func NewSeqSet(nums ...uint32) SeqSet {
+	var s SeqSet
+	s.AddNum(nums...)
+	return s
+}
+
+// ParseSeqSet parses a sequence set such as "1:5,7,10:*".
+func ParseSeqSet(set string) (SeqSet, error) {
+	var s SeqSet
+	if set == "" {
+		return s, nil
+	}
+
+	for _, part := range strings.Split(set, ",") {
+		var start, stop uint32
+		var err error
+		if pos := strings.IndexByte(part, ':'); pos == -1 {
+			start, err = parseSeqNum(part)
+			stop = start
+		} else {
+			start, err = parseSeqNum(part[:pos])
+			if err == nil {
+				stop, err = parseSeqNum(part[pos+1:])
+			}
+		}
+		if err != nil {
+			return SeqSet{}, fmt.Errorf("failed to parse sequence set %q: %v", set, err)
+		}
+		s.AddRange(start, stop)
+	}
+	return s, nil
+}
+
+func parseSeqNum(s string) (uint32, error) {
+	if s == "*" {
+		return seqStar, nil
+	}
+	v, err := strconv.ParseUint(s, 10, 32)
+	if err != nil || v == 0 {
+		return 0, fmt.Errorf("unexpected number %q", s)
+	}
+	return uint32(v), nil
+}
+
+// AddNum adds nums to the set.
+func (s *SeqSet) AddNum(nums ...uint32) {
+	for _, n := range nums {
+		s.AddRange(n, n)
+	}
+}
+
+// AddRange adds start:stop to the set. seqStar (math.MaxUint32) is "*".
+func (s *SeqSet) AddRange(start, stop uint32) {
+	if start > stop {
+		start, stop = stop, start
+	}
+
+	// first range that may touch start:stop
+	i := sort.Search(len(s.ranges), func(i int) bool {
+		return s.ranges[i].stop == seqStar || s.ranges[i].stop+1 >= start
+	})
+	// ranges[i:j] are merged with start:stop
+	j := i
+	for ; j < len(s.ranges); j++ {
+		if stop != seqStar && s.ranges[j].start > stop+1 {
+			break
+		}
+		if s.ranges[j].start < start {
+			start = s.ranges[j].start
+		}
+		if s.ranges[j].stop > stop {
+			stop = s.ranges[j].stop
+		}
+	}
+
+	merged := append([]seqRange{{start, stop}}, s.ranges[j:]...)
+	s.ranges = append(s.ranges[:i], merged...)
+}
+
+// AddSet adds all numbers in other to the set.
+func (s *SeqSet) AddSet(other SeqSet) {
+	for _, r := range other.ranges {
+		s.AddRange(r.start, r.stop)
+	}
+}
+
+// RemoveNum removes nums from the set.
+func (s *SeqSet) RemoveNum(nums ...uint32) {
+	for _, n := range nums {
+		s.RemoveRange(n, n)
+	}
+}
+
+// RemoveRange removes start:stop from the set.
+func (s *SeqSet) RemoveRange(start, stop uint32) {
+	if start > stop {
+		start, stop = stop, start
+	}
+
+	ranges := make([]seqRange, 0, len(s.ranges)+1)
+	for _, r := range s.ranges {
+		if r.stop < start || stop < r.start {
+			ranges = append(ranges, r)
+			continue
+		}
+		if r.start < start {
+			ranges = append(ranges, seqRange{r.start, start - 1})
+		}
+		if stop < r.stop {
+			ranges = append(ranges, seqRange{stop + 1, r.stop})
+		}
+	}
+	s.ranges = ranges
+}
+
+// Contains reports whether num is in the set.
+func (s SeqSet) Contains(num uint32) bool {
+	i := sort.Search(len(s.ranges), func(i int) bool {
+		return s.ranges[i].stop >= num
+	})
+	return i < len(s.ranges) && s.ranges[i].start <= num
+}
+
+func (s SeqSet) Empty() bool {
+	return len(s.ranges) == 0
+}
+
+// Dynamic reports whether the set contains "*".
+func (s SeqSet) Dynamic() bool {
+	return len(s.ranges) > 0 && s.ranges[len(s.ranges)-1].stop == seqStar
+}
+
+// Nums returns all numbers in the set in ascending order.
+// It fails if the set is Dynamic, since the value of "*" is known only to the server.
+func (s SeqSet) Nums() ([]uint32, error) {
+	if s.Dynamic() {
+		return nil, fmt.Errorf("sequence set %v contains *", s)
+	}
+
+	nums := make([]uint32, 0, len(s.ranges))
+	s.Each(func(num uint32) bool {
+		nums = append(nums, num)
+		return true
+	})
+	return nums, nil
+}
+
+// Each calls fn with every number in the set in ascending order until fn returns false.
+// A range up to "*" is iterated up to math.MaxUint32-1.
+func (s SeqSet) Each(fn func(num uint32) bool) {
+	for _, r := range s.ranges {
+		stop := r.stop
+		if stop == seqStar {
+			if r.start == seqStar {
+				continue
+			}
+			stop--
+		}
+		for n := r.start; ; n++ {
+			if !fn(n) {
+				return
+			}
+			if n == stop {
+				break
+			}
+		}
+	}
+}
+
+// String returns the set in the IMAP syntax, such as "1:5,7,10:*".
+func (s SeqSet) String() string {
+	parts := make([]string, 0, len(s.ranges))
+	for _, r := range s.ranges {
+		parts = append(parts, r.String())
+	}
+	return strings.Join(parts, ",")
+}
+
+func (r seqRange) String() string {
+	start := formatSeqNum(r.start)
+	if r.start == r.stop {
+		return start
+	}
+	return start + ":" + formatSeqNum(r.stop)
+}
+
+func formatSeqNum(n uint32) string {
+	if n == seqStar {
+		return "*"
+	}
+	return strconv.FormatUint(uint64(n), 10)
+}
+
+// Split splits the set into sets whose String() are not longer than maxLen.
+// A set that is short enough is returned as it is.
+func (s SeqSet) Split(maxLen int) []SeqSet {
+	sets := make([]SeqSet, 0, 1)
+
+	var cur SeqSet
+	curLen := 0
+	for _, r := range s.ranges {
+		rLen := len(r.String())
+		if len(cur.ranges) > 0 && curLen+1+rLen > maxLen {
+			sets = append(sets, cur)
+			cur = SeqSet{}
+			curLen = 0
+		}
+		if len(cur.ranges) > 0 {
+			curLen++ // ","
+		}
+		cur.ranges = append(cur.ranges, r)
+		curLen += rLen
+	}
+	if len(cur.ranges) > 0 || len(sets) == 0 {
+		sets = append(sets, cur)
+	}
+	return sets
+}
+
+// splitSeqSet splits seqSet into sequence sets short enough for a command line.
+// seqSet that is not a sequence set (like "$") is returned as it is.
+func splitSeqSet(seqSet string) []string {
+	if len(seqSet) <= maxSeqSetLength {
+		return []string{seqSet}
+	}
+
+	s, err := ParseSeqSet(seqSet)
+	if err != nil {
+		return []string{seqSet}
+	}
+
+	sets := s.Split(maxSeqSetLength)
+	strs := make([]string, 0, len(sets))
+	for _, set := range sets {
+		strs = append(strs, set.String())
+	}
+	return strs
+}
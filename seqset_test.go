@@ -0,0 +1,72 @@
+package imapclient
+
+import (
+	"testing"
+)
+
+func TestSeqSet(t *testing.T) {
+	s, err := ParseSeqSet("10:*,1:5,7,6")
+	if err != nil {
+		t.Fatalf("ParseSeqSet: %v", err)
+	}
+	if str := s.String(); str != "1:7,10:*" {
+		t.Errorf("String() %v", str)
+	}
+
+	s.RemoveRange(3, 4)
+	s.RemoveNum(11)
+	s.AddNum(8)
+	if str := s.String(); str != "1:2,5:8,10,12:*" {
+		t.Errorf("String() %v", str)
+	}
+
+	for _, n := range []uint32{1, 2, 5, 8, 10, 12, 100000} {
+		if !s.Contains(n) {
+			t.Errorf("Contains(%v) false", n)
+		}
+	}
+	for _, n := range []uint32{3, 4, 9, 11} {
+		if s.Contains(n) {
+			t.Errorf("Contains(%v) true", n)
+		}
+	}
+
+	if _, err := s.Nums(); err == nil {
+		t.Errorf("Nums() of a dynamic set must fail")
+	}
+	s.RemoveRange(10, seqStar)
+	nums, err := s.Nums()
+	if err != nil || len(nums) != 6 || nums[0] != 1 || nums[5] != 8 {
+		t.Errorf("Nums() %v, %v", nums, err)
+	}
+
+	if _, err := ParseSeqSet("1:x"); err == nil {
+		t.Errorf("ParseSeqSet(1:x) must fail")
+	}
+}
+
+func TestSeqSetSplit(t *testing.T) {
+	var s SeqSet
+	for n := uint32(1); n < 2000; n += 2 {
+		s.AddNum(n)
+	}
+
+	sets := s.Split(100)
+	if len(sets) < 2 {
+		t.Fatalf("Split() %v sets", len(sets))
+	}
+	var merged SeqSet
+	for _, set := range sets {
+		if l := len(set.String()); l > 100 {
+			t.Errorf("too long (%v)", l)
+		}
+		merged.AddSet(set)
+	}
+	if merged.String() != s.String() {
+		t.Errorf("merged %v", merged)
+	}
+
+	if strs := splitSeqSet("$"); len(strs) != 1 || strs[0] != "$" {
+		t.Errorf("splitSeqSet($) %v", strs)
+	}
+}
@@ -0,0 +1,189 @@
+package imapclient
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SortKey is a sort criterion of SORT (RFC 5256).
+type SortKey string
+
+const (
+	SortArrival SortKey = "ARRIVAL"
+	SortCc      SortKey = "CC"
+	SortDate    SortKey = "DATE"
+	SortFrom    SortKey = "FROM"
+	SortSize    SortKey = "SIZE"
+	SortSubject SortKey = "SUBJECT"
+	SortTo      SortKey = "TO"
+)
+
+// Reverse returns k in the reverse order.
+func (k SortKey) Reverse() SortKey {
+	return "REVERSE " + k
+}
+
+// ThreadAlgorithm is a threading algorithm of THREAD (RFC 5256).
+type ThreadAlgorithm string
+
+const (
+	ThreadOrderedSubject ThreadAlgorithm = "ORDEREDSUBJECT"
+	ThreadReferences     ThreadAlgorithm = "REFERENCES"
+)
+
+// ThreadNode is a message in a thread tree.
+// Num is 0 for a missing parent of its children.
+type ThreadNode struct {
+	Num      uint32
+	Children []*ThreadNode
+}
+
+// Sort searches the selected mailbox by searchCriteria and returns sequence numbers sorted by criteria.
+// It fails if the server does not support SORT.
+func (c *Client) Sort(criteria []SortKey, searchCriteria *SearchCriteria) ([]uint32, error) {
+	return c.sort("SORT", criteria, searchCriteria)
+}
+
+// UIDSort is Sort returning UIDs instead of sequence numbers.
+func (c *Client) UIDSort(criteria []SortKey, searchCriteria *SearchCriteria) ([]uint32, error) {
+	return c.sort("UID SORT", criteria, searchCriteria)
+}
+
+func (c *Client) sort(cmd string, criteria []SortKey, searchCriteria *SearchCriteria) ([]uint32, error) {
+	hasSort, err := c.HasCapability("SORT")
+	if err != nil {
+		return nil, err
+	}
+	if !hasSort {
+		return nil, fmt.Errorf("the server does not support SORT")
+	}
+
+	if len(criteria) == 0 {
+		criteria = []SortKey{SortArrival}
+	}
+	keys := make([]string, 0, len(criteria))
+	for _, k := range criteria {
+		keys = append(keys, string(k))
+	}
+
+	lc := newLiteralCommand(fmt.Sprintf("%s (%s) UTF-8 ", cmd, strings.Join(keys, " ")))
+	searchCriteria.appendTo(lc)
+
+	res, err := c.commandLiterals(lc)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint32, 0, 10)
+	for _, line := range untaggedLines(res, "SORT") {
+		for _, f := range strings.Fields(line[len("SORT"):]) {
+			id, err := fieldUint32(f)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse SORT: %v", err)
+			}
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// Thread searches the selected mailbox by criteria and returns the threads of matched messages.
+// It fails if the server does not support THREAD=algorithm.
+func (c *Client) Thread(algorithm ThreadAlgorithm, criteria *SearchCriteria) ([]*ThreadNode, error) {
+	return c.thread("THREAD", algorithm, criteria)
+}
+
+// UIDThread is Thread returning UIDs instead of sequence numbers.
+func (c *Client) UIDThread(algorithm ThreadAlgorithm, criteria *SearchCriteria) ([]*ThreadNode, error) {
+	return c.thread("UID THREAD", algorithm, criteria)
+}
+
+func (c *Client) thread(cmd string, algorithm ThreadAlgorithm, criteria *SearchCriteria) ([]*ThreadNode, error) {
+	hasThread, err := c.HasCapability("THREAD=" + string(algorithm))
+	if err != nil {
+		return nil, err
+	}
+	if !hasThread {
+		return nil, fmt.Errorf("the server does not support THREAD=%v", algorithm)
+	}
+
+	lc := newLiteralCommand(fmt.Sprintf("%s %s UTF-8 ", cmd, algorithm))
+	criteria.appendTo(lc)
+
+	res, err := c.commandLiterals(lc)
+	if err != nil {
+		return nil, err
+	}
+
+	threads := make([]*ThreadNode, 0, 10)
+	for _, line := range untaggedLines(res, "THREAD") {
+		nodes, err := parseThread(line[len("THREAD"):])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse THREAD: %v", err)
+		}
+		threads = append(threads, nodes...)
+	}
+	return threads, nil
+}
+
+// parseThread parses thread-lists such as "(2)(3 6 (4 23)(44 7 96))".
+func parseThread(s string) ([]*ThreadNode, error) {
+	fields, err := parseFields(s)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make([]*ThreadNode, 0, len(fields))
+	for _, f := range fields {
+		list, ok := f.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unexpected %v", f)
+		}
+		node, err := buildThreadNode(list)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// buildThreadNode builds a node from a thread-list.
+// Numbers are a chain of parent and child, and nested lists are children of the last number.
+func buildThreadNode(list []interface{}) (*ThreadNode, error) {
+	root := &ThreadNode{}
+	var last *ThreadNode
+
+	for _, f := range list {
+		switch v := f.(type) {
+		case string:
+			num, err := fieldUint32(v)
+			if err != nil {
+				return nil, err
+			}
+			if last == nil {
+				root.Num = num
+				last = root
+			} else {
+				child := &ThreadNode{Num: num}
+				last.Children = append(last.Children, child)
+				last = child
+			}
+
+		case []interface{}:
+			if last == nil {
+				last = root // no parent number
+			}
+			child, err := buildThreadNode(v)
+			if err != nil {
+				return nil, err
+			}
+			last.Children = append(last.Children, child)
+
+		default:
+			return nil, fmt.Errorf("unexpected %v", f)
+		}
+	}
+
+	return root, nil
+}
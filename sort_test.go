@@ -0,0 +1,162 @@
+package imapclient
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseThread(t *testing.T) {
+	nodes, err := parseThread(" (2)(3 6 (4 23)(44 7 96))((5)(8))")
+	if err != nil {
+		t.Fatalf("parseThread: %v", err)
+	}
+
+	expect := []string{"2", "3[6[4[23],44[7[96]]]]", "0[5,8]"}
+	if len(nodes) != len(expect) {
+		t.Fatalf("len %v, expected %v", len(nodes), len(expect))
+	}
+	for i, n := range nodes {
+		if s := formatThread(n); s != expect[i] {
+			t.Errorf("thread %v: %v, expected %v", i, s, expect[i])
+		}
+	}
+}
+
+func TestSort(t *testing.T) {
+	cases := []struct {
+		uid    bool
+		keys   []SortKey
+		sc     *SearchCriteria
+		caps   string
+		cmd    string // "" if refused
+		lit    string
+		res    string
+		expect string
+	}{
+		{false, nil, NewSearchCriteria().All(), "IMAP4rev1 SORT", "A1 SORT (ARRIVAL) UTF-8 ALL", "", "* SORT 2 1 3", "[2 1 3]"},
+		{
+			true, []SortKey{SortDate.Reverse(), SortSubject}, NewSearchCriteria().NoFlag(FlagSeen), "IMAP4rev1 SORT",
+			"A1 UID SORT (REVERSE DATE SUBJECT) UTF-8 UNSEEN", "", "* SORT 30 10", "[30 10]",
+		},
+		{
+			false, []SortKey{SortSize.Reverse()}, NewSearchCriteria().Subject("日本語"), "IMAP4rev1 SORT",
+			"A1 SORT (REVERSE SIZE) UTF-8 SUBJECT {9}", "日本語", "* SORT", "[]",
+		},
+		{false, nil, NewSearchCriteria().All(), "IMAP4rev1 THREAD=REFERENCES", "", "", "", ""},
+	}
+
+	for i, cs := range cases {
+		c, done := newFakeServer(t, func(s *fakeServer) {
+			if cs.cmd == "" {
+				return
+			}
+			s.expect(cs.cmd)
+			if cs.lit != "" {
+				s.send("+ Ready")
+				s.read(len(cs.lit))
+				s.expect("")
+			}
+			s.send(cs.res, "A1 OK SORT completed")
+		})
+		c.caps = strings.Fields(cs.caps)
+		c.mailbox = &MailboxStatus{Name: "INBOX"}
+
+		var ids []uint32
+		var err error
+		if cs.uid {
+			ids, err = c.UIDSort(cs.keys, cs.sc)
+		} else {
+			ids, err = c.Sort(cs.keys, cs.sc)
+		}
+		<-done
+
+		switch {
+		case cs.cmd == "" && err == nil:
+			t.Errorf("%v: Sort succeeded without SORT, expected an error", i)
+		case cs.cmd != "" && err != nil:
+			t.Errorf("%v: Sort: %v", i, err)
+		case err == nil && fmt.Sprint(ids) != cs.expect:
+			t.Errorf("%v: Sort %v, expected %v", i, ids, cs.expect)
+		}
+	}
+}
+
+func TestThread(t *testing.T) {
+	cases := []struct {
+		uid       bool
+		algorithm ThreadAlgorithm
+		sc        *SearchCriteria
+		caps      string
+		cmd       string // "" if refused
+		lit       string
+		res       string
+		expect    string
+	}{
+		{
+			false, ThreadReferences, NewSearchCriteria().All(), "IMAP4rev1 THREAD=ORDEREDSUBJECT THREAD=REFERENCES",
+			"A1 THREAD REFERENCES UTF-8 ALL", "", "* THREAD (2)(3 6 (4 23)(44 7 96))", "2,3[6[4[23],44[7[96]]]]",
+		},
+		{
+			true, ThreadOrderedSubject, NewSearchCriteria().From("日本"), "IMAP4rev1 THREAD=ORDEREDSUBJECT",
+			"A1 UID THREAD ORDEREDSUBJECT UTF-8 FROM {6}", "日本", "* THREAD ((30)(40))", "0[30,40]",
+		},
+		{false, ThreadReferences, NewSearchCriteria().All(), "IMAP4rev1 SORT THREAD=ORDEREDSUBJECT", "", "", "", ""},
+	}
+
+	for i, cs := range cases {
+		c, done := newFakeServer(t, func(s *fakeServer) {
+			if cs.cmd == "" {
+				return
+			}
+			s.expect(cs.cmd)
+			if cs.lit != "" {
+				s.send("+ Ready")
+				s.read(len(cs.lit))
+				s.expect("")
+			}
+			s.send(cs.res, "A1 OK THREAD completed")
+		})
+		c.caps = strings.Fields(cs.caps)
+		c.mailbox = &MailboxStatus{Name: "INBOX"}
+
+		var nodes []*ThreadNode
+		var err error
+		if cs.uid {
+			nodes, err = c.UIDThread(cs.algorithm, cs.sc)
+		} else {
+			nodes, err = c.Thread(cs.algorithm, cs.sc)
+		}
+		<-done
+
+		if cs.cmd == "" {
+			if err == nil {
+				t.Errorf("%v: Thread succeeded without THREAD=%v, expected an error", i, cs.algorithm)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: Thread: %v", i, err)
+			continue
+		}
+		threads := make([]string, 0, len(nodes))
+		for _, n := range nodes {
+			threads = append(threads, formatThread(n))
+		}
+		if s := strings.Join(threads, ","); s != cs.expect {
+			t.Errorf("%v: Thread %v, expected %v", i, s, cs.expect)
+		}
+	}
+}
+
+// formatThread formats n as "num[child,child...]".
+func formatThread(n *ThreadNode) string {
+	children := make([]string, 0, len(n.Children))
+	for _, c := range n.Children {
+		children = append(children, formatThread(c))
+	}
+	if len(children) == 0 {
+		return fmt.Sprint(n.Num)
+	}
+	return fmt.Sprintf("%v[%v]", n.Num, strings.Join(children, ","))
+}
@@ -0,0 +1,157 @@
+package imapclient
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MailboxAttr is a set of mailbox attributes of LIST.
+type MailboxAttr uint32
+
+const (
+	AttrNoinferiors MailboxAttr = 1 << iota
+	AttrNoselect
+	AttrMarked
+	AttrUnmarked
+	AttrHasChildren
+	AttrHasNoChildren
+	AttrNonExistent
+	AttrSubscribed
+	AttrRemote
+
+	// special-use (RFC 6154)
+
+	AttrAll
+	AttrArchive
+	AttrDrafts
+	AttrFlagged
+	AttrJunk
+	AttrSent
+	AttrTrash
+)
+
+// specialUseAttrs are the special-use attributes.
+const specialUseAttrs = AttrAll | AttrArchive | AttrDrafts | AttrFlagged | AttrJunk | AttrSent | AttrTrash
+
+var mailboxAttrNames = []struct {
+	attr MailboxAttr
+	name string
+}{
+	{AttrNoinferiors, "\\Noinferiors"},
+	{AttrNoselect, "\\Noselect"},
+	{AttrMarked, "\\Marked"},
+	{AttrUnmarked, "\\Unmarked"},
+	{AttrHasChildren, "\\HasChildren"},
+	{AttrHasNoChildren, "\\HasNoChildren"},
+	{AttrNonExistent, "\\NonExistent"},
+	{AttrSubscribed, "\\Subscribed"},
+	{AttrRemote, "\\Remote"},
+	{AttrAll, "\\All"},
+	{AttrArchive, "\\Archive"},
+	{AttrDrafts, "\\Drafts"},
+	{AttrFlagged, "\\Flagged"},
+	{AttrJunk, "\\Junk"},
+	{AttrSent, "\\Sent"},
+	{AttrTrash, "\\Trash"},
+}
+
+// ParseMailboxAttrs parses attributes such as `\Noselect`. Unknown attributes are ignored.
+func ParseMailboxAttrs(attrs []string) MailboxAttr {
+	var a MailboxAttr
+	for _, attr := range attrs {
+		for _, an := range mailboxAttrNames {
+			if strings.EqualFold(attr, an.name) {
+				a |= an.attr
+				break
+			}
+		}
+	}
+	return a
+}
+
+// Has reports whether a has all of attr.
+func (a MailboxAttr) Has(attr MailboxAttr) bool {
+	return a&attr == attr
+}
+
+// String returns the attributes separated by a space, such as `\HasNoChildren \Sent`.
+func (a MailboxAttr) String() string {
+	names := make([]string, 0, 2)
+	for _, an := range mailboxAttrNames {
+		if a&an.attr != 0 {
+			names = append(names, an.name)
+		}
+	}
+	return strings.Join(names, " ")
+}
+
+// Attributes returns Attrs parsed.
+func (li ListItem) Attributes() MailboxAttr {
+	return ParseMailboxAttrs(li.Attrs)
+}
+
+// FindSpecialUse finds the mailbox for role, such as AttrSent (RFC 6154).
+// role must be one of the special-use attributes.
+// It returns nil if no mailbox has the role.
+func (c *Client) FindSpecialUse(role MailboxAttr) (*ListItem, error) {
+	if err := checkSpecialUse(role); err != nil {
+		return nil, err
+	}
+
+	specialUse, err := c.HasCapability("SPECIAL-USE")
+	if err != nil {
+		return nil, err
+	}
+	extended, err := c.HasCapability("LIST-EXTENDED")
+	if err != nil {
+		return nil, err
+	}
+
+	var items []ListItem
+	if specialUse && extended {
+		items, err = c.ListExtended(&ListOptions{ReturnSpecialUse: true}, "", "*")
+	} else {
+		// many servers return special-use attributes with LIST anyway
+		items, err = c.List("", "*")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range items {
+		if items[i].Attributes().Has(role) {
+			return &items[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// CreateSpecialUse creates mailbox for role, such as AttrSent (CREATE-SPECIAL-USE, RFC 6154).
+// role must be one of the special-use attributes.
+func (c *Client) CreateSpecialUse(mailbox string, role MailboxAttr) error {
+	if err := checkSpecialUse(role); err != nil {
+		return err
+	}
+	canCreate, err := c.HasCapability("CREATE-SPECIAL-USE")
+	if err != nil {
+		return err
+	}
+	if !canCreate {
+		return fmt.Errorf("the server does not support CREATE-SPECIAL-USE")
+	}
+
+	mailbox, err = c.quoteMailbox(mailbox)
+	if err != nil {
+		return fmt.Errorf("failed to encode mailbox: %v", err)
+	}
+	_, err = c.Command(fmt.Sprintf("CREATE %v (USE (%v))", mailbox, role))
+	return err
+}
+
+// checkSpecialUse returns an error unless role is exactly one special-use attribute.
+func checkSpecialUse(role MailboxAttr) error {
+	if role == 0 || role&^specialUseAttrs != 0 || role&(role-1) != 0 {
+		return fmt.Errorf("not a special-use attribute: %q", role.String())
+	}
+	return nil
+}
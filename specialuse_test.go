@@ -0,0 +1,91 @@
+package imapclient
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMailboxAttr(t *testing.T) {
+	item := ListItem{Attrs: []string{"\\HasNoChildren", "\\sent", "\\X-Unknown"}}
+
+	a := item.Attributes()
+	if !a.Has(AttrSent) || !a.Has(AttrHasNoChildren|AttrSent) || a.Has(AttrTrash) || a.Has(AttrNoselect) {
+		t.Errorf("unexpected attributes %v", a)
+	}
+	if s := a.String(); s != "\\HasNoChildren \\Sent" {
+		t.Errorf("String() %v", s)
+	}
+}
+
+func TestFindSpecialUse(t *testing.T) {
+	cases := []struct {
+		role   MailboxAttr
+		caps   string
+		cmd    string // "" if refused
+		expect string
+	}{
+		{AttrTrash, "IMAP4rev1 LIST-EXTENDED SPECIAL-USE", `A1 LIST "" "*" RETURN (SPECIAL-USE)`, "[Gmail]/Trash"},
+		{AttrTrash, "IMAP4rev1 SPECIAL-USE", `A1 LIST "" "*"`, "[Gmail]/Trash"},
+		{AttrSent, "IMAP4rev1", `A1 LIST "" "*"`, ""},
+		{0, "IMAP4rev1", "", ""},
+		{AttrHasChildren, "IMAP4rev1", "", ""},
+	}
+
+	for i, cs := range cases {
+		c, done := newFakeServer(t, func(s *fakeServer) {
+			if cs.cmd == "" {
+				return
+			}
+			s.expect(cs.cmd)
+			s.send(`* LIST (\HasChildren) "/" INBOX`, `* LIST (\HasNoChildren \Trash) "/" "[Gmail]/Trash"`, "A1 OK LIST completed")
+		})
+		c.caps = strings.Fields(cs.caps)
+
+		item, err := c.FindSpecialUse(cs.role)
+		<-done
+
+		switch {
+		case cs.cmd == "" && err == nil:
+			t.Errorf("%v: FindSpecialUse(%v) succeeded, expected an error", i, cs.role)
+		case cs.cmd != "" && err != nil:
+			t.Errorf("%v: FindSpecialUse(%v): %v", i, cs.role, err)
+		case err == nil && cs.expect == "" && item != nil:
+			t.Errorf("%v: FindSpecialUse(%v) %v, expected nil", i, cs.role, item.Name)
+		case err == nil && cs.expect != "" && (item == nil || item.Name != cs.expect):
+			t.Errorf("%v: FindSpecialUse(%v) %+v, expected %q", i, cs.role, item, cs.expect)
+		}
+	}
+}
+
+func TestCreateSpecialUse(t *testing.T) {
+	cases := []struct {
+		role   MailboxAttr
+		caps   string
+		expect string // the command, or "" if refused
+	}{
+		{AttrArchive, "IMAP4rev1 CREATE-SPECIAL-USE", `A1 CREATE "Archive" (USE (\Archive))`},
+		{AttrArchive, "IMAP4rev1 SPECIAL-USE", ""},
+		{0, "IMAP4rev1 CREATE-SPECIAL-USE", ""},
+		{AttrHasChildren, "IMAP4rev1 CREATE-SPECIAL-USE", ""},
+		{AttrSent | AttrTrash, "IMAP4rev1 CREATE-SPECIAL-USE", ""},
+	}
+
+	for i, cs := range cases {
+		c, done := newFakeServer(t, func(s *fakeServer) {
+			if cs.expect == "" {
+				return
+			}
+			s.expect(cs.expect)
+			s.send("A1 OK CREATE completed")
+		})
+		c.caps = strings.Fields(cs.caps)
+
+		err := c.CreateSpecialUse("Archive", cs.role)
+		if cs.expect == "" && err == nil {
+			t.Errorf("%v: CreateSpecialUse(%q) succeeded, expected an error", i, cs.role.String())
+		} else if cs.expect != "" && err != nil {
+			t.Errorf("%v: CreateSpecialUse(%q): %v", i, cs.role.String(), err)
+		}
+		<-done
+	}
+}
@@ -0,0 +1,164 @@
+package imapclient
+
+import (
+	"fmt"
+	"strings"
+)
+
+// StoreOp is the way STORE changes flags.
+type StoreOp int
+
+const (
+	StoreReplace StoreOp = iota // FLAGS
+	StoreAdd                    // +FLAGS
+	StoreRemove                 // -FLAGS
+)
+
+func (op StoreOp) dataItem(silent bool) string {
+	var item string
+	switch op {
+	case StoreAdd:
+		item = "+FLAGS"
+	case StoreRemove:
+		item = "-FLAGS"
+	default:
+		item = "FLAGS"
+	}
+	if silent {
+		item += ".SILENT"
+	}
+	return item
+}
+
+// StoreOptions are optional parameters of StoreFlags.
+type StoreOptions struct {
+	// Silent suppresses the FETCH responses of the updated flags (.SILENT).
+	Silent bool
+	// UnchangedSince stores only to messages whose mod-sequence is not greater than it (CONDSTORE, RFC 7162),
+	// if not nil. 0 stores only to messages that have no mod-sequence yet.
+	UnchangedSince *uint64
+}
+
+// MessageFlags are the flags of a message reported by FETCH.
+// UID and ModSeq are 0 unless the server reported them.
+type MessageFlags struct {
+	SeqNum uint32
+	UID    uint32
+	Flags  []string
+	ModSeq uint64
+}
+
+// StoreResult is the result of StoreFlags.
+type StoreResult struct {
+	// Messages are the flags after the STORE (and the ones changed by others meanwhile).
+	Messages []MessageFlags
+	// Modified are the messages not stored because of UnchangedSince.
+	Modified SeqSet
+}
+
+// StoreFlags changes the flags of messages in seqSet.
+func (c *Client) StoreFlags(seqSet string, op StoreOp, flags []string, opts *StoreOptions) (*StoreResult, error) {
+	return c.storeFlags("STORE", seqSet, op, flags, opts)
+}
+
+// UIDStoreFlags is StoreFlags taking a set of UIDs. Modified of the result is also UIDs.
+func (c *Client) UIDStoreFlags(uidSet string, op StoreOp, flags []string, opts *StoreOptions) (*StoreResult, error) {
+	return c.storeFlags("UID STORE", uidSet, op, flags, opts)
+}
+
+func (c *Client) storeFlags(cmd, seqSet string, op StoreOp, flags []string, opts *StoreOptions) (*StoreResult, error) {
+	if opts == nil {
+		opts = &StoreOptions{}
+	}
+
+	var modifier string
+	if opts.UnchangedSince != nil {
+		if !c.Enabled("CONDSTORE") && !c.Enabled("QRESYNC") {
+			hasCondStore, err := c.HasCapability("CONDSTORE")
+			if err != nil {
+				return nil, err
+			}
+			if !hasCondStore {
+				return nil, fmt.Errorf("the server does not support CONDSTORE")
+			}
+		}
+		modifier = fmt.Sprintf("(UNCHANGEDSINCE %d) ", *opts.UnchangedSince)
+	}
+
+	result := &StoreResult{}
+	for _, set := range splitSeqSet(seqSet) {
+		res, err := c.Command(fmt.Sprintf("%s %s %s%s (%s)", cmd, set, modifier, op.dataItem(opts.Silent), strings.Join(flags, " ")))
+		if err != nil {
+			return nil, err
+		}
+
+		msgs, err := parseFetchFlags(res)
+		if err != nil {
+			return nil, err
+		}
+		result.Messages = append(result.Messages, msgs...)
+
+		if arg, found := responseCode(res, "MODIFIED"); found {
+			modified, err := ParseSeqSet(arg)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse MODIFIED: %v", err)
+			}
+			result.Modified.AddSet(modified)
+		}
+	}
+	return result, nil
+}
+
+// parseFetchFlags parses FLAGS, UID and MODSEQ of FETCH responses in res.
+func parseFetchFlags(res string) ([]MessageFlags, error) {
+	msgs := make([]MessageFlags, 0, 4)
+
+	for _, line := range splitResponse(res) {
+		seq, items, found := fetchItems(line)
+		if !found {
+			continue
+		}
+
+		fields, err := parseFields(items)
+		if err != nil || len(fields) != 1 {
+			return nil, fmt.Errorf("failed to parse FETCH %q: %v", line, err)
+		}
+
+		msg := MessageFlags{SeqNum: seq}
+		attrs := fieldList(fields[0])
+		for i := 0; i+1 < len(attrs); i += 2 {
+			switch strings.ToUpper(fieldString(attrs[i])) {
+			case "FLAGS":
+				msg.Flags = fieldStrings(attrs[i+1])
+			case "UID":
+				msg.UID, err = fieldUint32(attrs[i+1])
+			case "MODSEQ":
+				if modseq := fieldList(attrs[i+1]); len(modseq) == 1 {
+					msg.ModSeq, err = fieldUint64(modseq[0])
+				}
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse FETCH %q: %v", line, err)
+			}
+		}
+		msgs = append(msgs, msg)
+	}
+
+	return msgs, nil
+}
+
+// fetchItems splits "* 12 FETCH (...)" into 12 and "(...)".
+func fetchItems(line string) (seq uint32, items string, found bool) {
+	if !strings.HasPrefix(line, "* ") {
+		return 0, "", false
+	}
+	comps := strings.SplitN(line[2:], " ", 3)
+	if len(comps) != 3 || !strings.EqualFold(comps[1], "FETCH") {
+		return 0, "", false
+	}
+	seq, err := fieldUint32(comps[0])
+	if err != nil {
+		return 0, "", false
+	}
+	return seq, comps[2], true
+}
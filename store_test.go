@@ -0,0 +1,85 @@
+package imapclient
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestStoreFlags(t *testing.T) {
+	modSeq := func(v uint64) *uint64 { return &v }
+
+	cases := []struct {
+		op      StoreOp
+		opts    *StoreOptions
+		caps    []string
+		enabled map[string]bool
+		expect  string // the command, or "" if refused
+	}{
+		{StoreReplace, nil, nil, nil, `A1 STORE 1:3 FLAGS (\Seen \Flagged)`},
+		{StoreAdd, &StoreOptions{Silent: true}, nil, nil, `A1 STORE 1:3 +FLAGS.SILENT (\Seen \Flagged)`},
+		{
+			StoreRemove, &StoreOptions{UnchangedSince: modSeq(12345)}, []string{"IMAP4rev1", "CONDSTORE"}, nil,
+			`A1 STORE 1:3 (UNCHANGEDSINCE 12345) -FLAGS (\Seen \Flagged)`,
+		},
+		{
+			StoreAdd, &StoreOptions{UnchangedSince: modSeq(0)}, nil, map[string]bool{"QRESYNC": true},
+			`A1 STORE 1:3 (UNCHANGEDSINCE 0) +FLAGS (\Seen \Flagged)`,
+		},
+		{StoreAdd, &StoreOptions{UnchangedSince: modSeq(0)}, []string{"IMAP4rev1"}, nil, ""},
+	}
+
+	for i, cs := range cases {
+		c, done := newFakeServer(t, func(s *fakeServer) {
+			if cs.expect == "" {
+				return
+			}
+			s.expect(cs.expect)
+			s.send(`* 1 FETCH (FLAGS (\Seen \Flagged) MODSEQ (12346))`, "A1 OK STORE completed")
+		})
+		c.caps = cs.caps
+		c.enabled = cs.enabled
+		c.mailbox = &MailboxStatus{Name: "INBOX"}
+
+		result, err := c.StoreFlags("1:3", cs.op, []string{FlagSeen, FlagFlagged}, cs.opts)
+		switch {
+		case cs.expect == "" && err == nil:
+			t.Errorf("%v: StoreFlags succeeded without CONDSTORE, expected an error", i)
+		case cs.expect != "" && err != nil:
+			t.Errorf("%v: StoreFlags: %v", i, err)
+		case err == nil && (len(result.Messages) != 1 || result.Messages[0].ModSeq != 12346 || !result.Modified.Empty()):
+			t.Errorf("%v: unexpected result %#v", i, result)
+		}
+		<-done
+	}
+}
+
+func TestStoreFlagsModified(t *testing.T) {
+	// a set longer than maxSeqSetLength is split
+	var set SeqSet
+	for n := uint32(1); n < 800; n += 2 {
+		set.AddNum(n)
+	}
+	sets := splitSeqSet(set.String())
+	if len(sets) != 2 {
+		t.Fatalf("split into %v sets, expected 2", len(sets))
+	}
+
+	c, done := newFakeServer(t, func(s *fakeServer) {
+		s.expect(fmt.Sprintf(`A1 UID STORE %v (UNCHANGEDSINCE 100) +FLAGS.SILENT (\Deleted)`, sets[0]))
+		s.send("A1 OK [MODIFIED 7,9] Conditional STORE failed")
+		s.expect(fmt.Sprintf(`A2 UID STORE %v (UNCHANGEDSINCE 100) +FLAGS.SILENT (\Deleted)`, sets[1]))
+		s.send("A2 OK [MODIFIED 701] Conditional STORE failed")
+	})
+	c.enabled = map[string]bool{"CONDSTORE": true}
+	c.mailbox = &MailboxStatus{Name: "INBOX"}
+
+	unchangedSince := uint64(100)
+	result, err := c.UIDStoreFlags(set.String(), StoreAdd, []string{FlagDeleted}, &StoreOptions{Silent: true, UnchangedSince: &unchangedSince})
+	if err != nil {
+		t.Fatalf("UIDStoreFlags: %v", err)
+	}
+	if s := result.Modified.String(); s != "7,9,701" {
+		t.Errorf("Modified %q, expected %q", s, "7,9,701")
+	}
+	<-done
+}
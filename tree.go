@@ -0,0 +1,173 @@
+package imapclient
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MailboxTree is the hierarchy of mailboxes built from List results.
+type MailboxTree struct {
+	// Root is the top of the tree, which is not a mailbox. Its children are the top level mailboxes.
+	Root *MailboxNode
+
+	delim string // the default delimiter
+	nodes map[string]*MailboxNode
+}
+
+// MailboxNode is a mailbox in MailboxTree.
+type MailboxNode struct {
+	// Name is the full name, such as "Work/2017".
+	Name  string
+	Delim string
+	// Item is the result of List, or nil if the mailbox was not listed (a parent implied by its children).
+	Item *ListItem
+
+	Parent   *MailboxNode
+	Children []*MailboxNode
+}
+
+// NewMailboxTree builds a tree from items.
+func NewMailboxTree(items []ListItem) *MailboxTree {
+	t := &MailboxTree{
+		Root:  &MailboxNode{},
+		nodes: make(map[string]*MailboxNode),
+	}
+
+	for i := range items {
+		if t.delim == "" {
+			t.delim = items[i].Delim
+		}
+	}
+	for i := range items {
+		item := items[i]
+		n := t.ensure(item.Name, item.Delim)
+		n.Item = &item
+	}
+	return t
+}
+
+// Leaf returns the last component of the name.
+func (n *MailboxNode) Leaf() string {
+	parts := splitMailboxPath(n.Name, n.Delim)
+	return parts[len(parts)-1]
+}
+
+// Delim returns the hierarchy delimiter of the tree, or "" if it is flat.
+func (t *MailboxTree) Delim() string {
+	return t.delim
+}
+
+// Find returns the node of name, or nil.
+func (t *MailboxTree) Find(name string) *MailboxNode {
+	return t.nodes[name]
+}
+
+// Walk calls fn with every node in depth first order.
+// depth is 0 for the top level mailboxes.
+// If fn returns an error, Walk stops and returns it.
+func (t *MailboxTree) Walk(fn func(n *MailboxNode, depth int) error) error {
+	return walkMailboxNodes(t.Root.Children, 0, fn)
+}
+
+func walkMailboxNodes(nodes []*MailboxNode, depth int, fn func(n *MailboxNode, depth int) error) error {
+	for _, n := range nodes {
+		if err := fn(n, depth); err != nil {
+			return err
+		}
+		if err := walkMailboxNodes(n.Children, depth+1, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreatePath creates the mailbox name and its missing parents, and adds them to the tree.
+// Mailboxes listed as \NonExistent are missing.
+func (t *MailboxTree) CreatePath(c *Client, name string) error {
+	parts := splitMailboxPath(name, t.delim)
+	for i := range parts {
+		path := strings.Join(parts[:i+1], t.delim)
+
+		n := t.nodes[path]
+		if n != nil && n.Item != nil && !n.Item.hasAttr(`\NonExistent`) {
+			continue
+		}
+		if err := c.Create(path); err != nil {
+			return fmt.Errorf("failed to create %v: %v", path, err)
+		}
+		n = t.ensure(path, t.delim)
+		n.Item = &ListItem{Name: path, Delim: t.delim}
+	}
+	return nil
+}
+
+// Rename renames the mailbox oldName and its children to newName, and moves them in the tree.
+func (t *MailboxTree) Rename(c *Client, oldName, newName string) error {
+	n := t.nodes[oldName]
+	if n == nil {
+		return fmt.Errorf("mailbox %v not found", oldName)
+	}
+	if _, found := t.nodes[newName]; found {
+		return fmt.Errorf("mailbox %v already exists", newName)
+	}
+
+	// the server renames the children too
+	if err := c.Rename(oldName, newName); err != nil {
+		return err
+	}
+
+	n.Parent.removeChild(n)
+	walkMailboxNodes([]*MailboxNode{n}, 0, func(m *MailboxNode, _ int) error {
+		delete(t.nodes, m.Name)
+		m.Name = newName + strings.TrimPrefix(m.Name, oldName)
+		if m.Item != nil {
+			item := *m.Item
+			item.Name = m.Name
+			m.Item = &item
+		}
+		t.nodes[m.Name] = m
+		return nil
+	})
+
+	parent := t.Root
+	if parts := splitMailboxPath(newName, n.Delim); len(parts) > 1 {
+		parent = t.ensure(strings.Join(parts[:len(parts)-1], n.Delim), n.Delim)
+	}
+	n.Parent = parent
+	parent.Children = append(parent.Children, n)
+	return nil
+}
+
+// ensure returns the node of name, adding it and its parents if missing.
+func (t *MailboxTree) ensure(name, delim string) *MailboxNode {
+	if n, found := t.nodes[name]; found {
+		return n
+	}
+
+	parent := t.Root
+	if parts := splitMailboxPath(name, delim); len(parts) > 1 {
+		parent = t.ensure(strings.Join(parts[:len(parts)-1], delim), delim)
+	}
+
+	n := &MailboxNode{Name: name, Delim: delim, Parent: parent}
+	parent.Children = append(parent.Children, n)
+	t.nodes[name] = n
+	return n
+}
+
+func (n *MailboxNode) removeChild(child *MailboxNode) {
+	for i, c := range n.Children {
+		if c == child {
+			n.Children = append(n.Children[:i], n.Children[i+1:]...)
+			return
+		}
+	}
+}
+
+// splitMailboxPath splits name by delim. A flat name (delim is "") is not split.
+func splitMailboxPath(name, delim string) []string {
+	if delim == "" {
+		return []string{name}
+	}
+	return strings.Split(name, delim)
+}
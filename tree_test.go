@@ -0,0 +1,205 @@
+package imapclient
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMailboxTree(t *testing.T) {
+	tree := NewMailboxTree([]ListItem{
+		{Name: "INBOX", Delim: "/"},
+		{Name: "Work/2017/Q1", Delim: "/"},
+		{Name: "Work", Delim: "/"},
+		{Name: "Work/2018", Delim: "/"},
+	})
+
+	if s, expect := treeString(tree), "INBOX,Work, 2017,  Q1, 2018"; s != expect {
+		t.Errorf("Walk %q, expected %q", s, expect)
+	}
+
+	cases := []struct {
+		name     string
+		found    bool
+		listed   bool
+		parent   string
+		children int
+	}{
+		{"Work/2017", true, false, "Work", 1},
+		{"Work", true, true, "", 2},
+		{"Work/2017/Q1", true, true, "Work/2017", 0},
+		{"Private", false, false, "", 0},
+	}
+	for i, cs := range cases {
+		n := tree.Find(cs.name)
+		if (n != nil) != cs.found {
+			t.Errorf("%v: Find(%q) %+v, expected found=%v", i, cs.name, n, cs.found)
+			continue
+		}
+		if n == nil {
+			continue
+		}
+		if (n.Item != nil) != cs.listed {
+			t.Errorf("%v: Find(%q).Item %+v, expected listed=%v", i, cs.name, n.Item, cs.listed)
+		}
+		if n.Parent.Name != cs.parent {
+			t.Errorf("%v: Find(%q).Parent %q, expected %q", i, cs.name, n.Parent.Name, cs.parent)
+		}
+		if len(n.Children) != cs.children {
+			t.Errorf("%v: Find(%q).Children %v, expected %v", i, cs.name, len(n.Children), cs.children)
+		}
+	}
+}
+
+func TestMailboxTreeCreatePath(t *testing.T) {
+	tree := NewMailboxTree([]ListItem{
+		{Name: "INBOX", Delim: "/"},
+		{Name: "Work/2017", Delim: "/"},
+	})
+
+	c, done := newFakeServer(t, func(s *fakeServer) {
+		// Work is implied by Work/2017, but does not exist
+		s.expect(`A1 CREATE "Work"`)
+		s.send("A1 OK CREATE completed")
+		s.expect(`A2 CREATE "Work/2018"`)
+		s.send("A2 OK CREATE completed")
+		s.expect(`A3 CREATE "Work/2018/Q1"`)
+		s.send("A3 OK CREATE completed")
+	})
+
+	if err := tree.CreatePath(c, "Work/2018/Q1"); err != nil {
+		t.Fatalf("CreatePath: %v", err)
+	}
+	<-done
+
+	if s, expect := treeString(tree), "INBOX,Work, 2017, 2018,  Q1"; s != expect {
+		t.Errorf("Walk %q, expected %q", s, expect)
+	}
+	for _, name := range []string{"Work", "Work/2018", "Work/2018/Q1"} {
+		if n := tree.Find(name); n == nil || n.Item == nil {
+			t.Errorf("Find(%q) %+v, expected listed", name, n)
+		}
+	}
+}
+
+func TestMailboxTreeCreatePathNonExistent(t *testing.T) {
+	// LIST-EXTENDED reports a missing parent of a subscribed mailbox as \NonExistent
+	tree := NewMailboxTree([]ListItem{
+		{Name: "Work", Delim: "/", Attrs: []string{`\NonExistent`, `\HasChildren`}},
+		{Name: "Work/2017", Delim: "/"},
+	})
+
+	c, done := newFakeServer(t, func(s *fakeServer) {
+		s.expect(`A1 CREATE "Work"`)
+		s.send("A1 OK CREATE completed")
+		s.expect(`A2 CREATE "Work/2018"`)
+		s.send("A2 OK CREATE completed")
+	})
+
+	if err := tree.CreatePath(c, "Work/2018"); err != nil {
+		t.Fatalf("CreatePath: %v", err)
+	}
+	<-done
+
+	if n := tree.Find("Work"); n == nil || n.Item == nil || n.Item.hasAttr(`\NonExistent`) {
+		t.Errorf("Find(Work) %+v, expected an existing mailbox", n)
+	}
+}
+
+func TestMailboxTreeCreatePathFailure(t *testing.T) {
+	tree := NewMailboxTree([]ListItem{{Name: "Work", Delim: "/"}})
+
+	c, done := newFakeServer(t, func(s *fakeServer) {
+		s.expect(`A1 CREATE "Work/2018"`)
+		s.send("A1 NO Permission denied")
+	})
+
+	if err := tree.CreatePath(c, "Work/2018/Q1"); err == nil {
+		t.Errorf("CreatePath succeeded, expected an error")
+	}
+	<-done
+
+	if n := tree.Find("Work/2018"); n != nil {
+		t.Errorf("Find(Work/2018) %+v, expected nil", n)
+	}
+}
+
+func TestMailboxTreeRename(t *testing.T) {
+	tree := NewMailboxTree([]ListItem{
+		{Name: "INBOX", Delim: "/"},
+		{Name: "Work", Delim: "/"},
+		{Name: "Work/2017", Delim: "/"},
+		{Name: "Work/2017/Q1", Delim: "/"},
+	})
+
+	c, done := newFakeServer(t, func(s *fakeServer) {
+		s.expect(`A1 RENAME "Work/2017" "Archive/2017"`)
+		s.send("A1 OK RENAME completed")
+	})
+
+	if err := tree.Rename(c, "Work/2017", "Archive/2017"); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	<-done
+
+	if s, expect := treeString(tree), "INBOX,Work,Archive, 2017,  Q1"; s != expect {
+		t.Errorf("Walk %q, expected %q", s, expect)
+	}
+
+	cases := []struct {
+		name   string
+		found  bool
+		parent string
+	}{
+		{"Work/2017", false, ""},
+		{"Work/2017/Q1", false, ""},
+		{"Archive", true, ""},
+		{"Archive/2017", true, "Archive"},
+		{"Archive/2017/Q1", true, "Archive/2017"},
+	}
+	for i, cs := range cases {
+		n := tree.Find(cs.name)
+		if (n != nil) != cs.found {
+			t.Errorf("%v: Find(%q) %+v, expected found=%v", i, cs.name, n, cs.found)
+			continue
+		}
+		if n == nil {
+			continue
+		}
+		if n.Parent.Name != cs.parent {
+			t.Errorf("%v: Find(%q).Parent %q, expected %q", i, cs.name, n.Parent.Name, cs.parent)
+		}
+		if n.Item != nil && n.Item.Name != cs.name {
+			t.Errorf("%v: Find(%q).Item.Name %q, expected %q", i, cs.name, n.Item.Name, cs.name)
+		}
+	}
+}
+
+func TestMailboxTreeRenameErrors(t *testing.T) {
+	tree := NewMailboxTree([]ListItem{
+		{Name: "Work", Delim: "/"},
+		{Name: "Archive", Delim: "/"},
+	})
+
+	cases := []struct {
+		oldName, newName string
+	}{
+		{"Private", "Personal"},
+		{"Work", "Archive"},
+	}
+	for i, cs := range cases {
+		// no command is sent
+		if err := tree.Rename(&Client{}, cs.oldName, cs.newName); err == nil {
+			t.Errorf("%v: Rename(%q, %q) succeeded, expected an error", i, cs.oldName, cs.newName)
+		}
+	}
+}
+
+// treeString returns the leaves of tree indented by their depth.
+func treeString(tree *MailboxTree) string {
+	var names []string
+	tree.Walk(func(n *MailboxNode, depth int) error {
+		names = append(names, strings.Repeat(" ", depth)+n.Leaf())
+		return nil
+	})
+	return strings.Join(names, ",")
+}